@@ -10,7 +10,10 @@ import (
     "cartophone-server/internal/constants"
     "cartophone-server/internal/handlers"
     "cartophone-server/internal/nfc"
+    "cartophone-server/internal/owntone"
+    "cartophone-server/internal/pocketbase"
     "cartophone-server/internal/alarms"
+    "cartophone-server/internal/sleeptimer"
 )
 
 func main() {
@@ -25,83 +28,210 @@ func main() {
         log.Fatalf("Failed to load configuration: %v", err)
     }
 
+    // Create the PocketBase and OwnTone clients shared by the handlers, the read mode and the alarms
+    pocketBase := pocketbase.NewClient(
+        config.PocketBaseURL,
+        &http.Client{Timeout: config.PocketBaseTimeout()},
+        config.PocketBaseCredentials(),
+    )
+    ownTone := owntone.NewClient(config.OwnToneBaseURL, &http.Client{Timeout: config.OwnToneTimeout()})
+
     // Initialize the NFC reader
-    reader, err := nfc.NewReader(config.DevicePath)
+    reader, err := nfc.NewReader(config.DevicePath, config.CardRetriggerWindow())
     if err != nil {
         log.Fatalf("Failed to initialize NFC reader: %v", err)
     }
     defer reader.Close()
 
     // Channels for NFC card detection and mode switching
-    cardDetectedChan := make(chan string)
+    cardDetectedChan := make(chan nfc.Event)
     modeSwitch := make(chan string)
 
     // Synchronization for mode state
     var modeLock sync.Mutex
     currentMode := constants.ReadMode
 
+    // Start the alarm checker
+    alarmChecker := alarms.StartAlarmChecker(pocketBase, ownTone, config.AlarmSettings())
+
+    // The sleep timer is shared by the HTTP API and the sleep timer cards
+    sleepTimer := sleeptimer.New(ownTone, config.SleepTimerSettings())
+
     // Use StartModeManager from handlers
     handlers.StartModeManager(
         modeSwitch,
         cardDetectedChan,
         &currentMode,
         &modeLock,
-        config.PocketBaseURL,
+        pocketBase,
+        ownTone,
+        alarmChecker,
+        config.AlarmCardAction,
+        sleepTimer,
     )
 
     // Start polling for NFC cards
     go reader.StartRead(cardDetectedChan)
 
-    // Start the alarm checker from utils
-    alarms.StartAlarmChecker(config.PocketBaseURL)
-
     // Set up HTTP routes for player management
     http.HandleFunc("/player/status", func(w http.ResponseWriter, r *http.Request) {
-        handlers.PlayerStatusHandler(config.OwnToneBaseURL, w, r)
+        handlers.PlayerStatusHandler(ownTone, sleepTimer, w, r)
+    })
+    http.HandleFunc("/player/sleep-timer", func(w http.ResponseWriter, r *http.Request) {
+        handlers.SleepTimerHandler(sleepTimer, w, r)
     })
     http.HandleFunc("/player/play", func(w http.ResponseWriter, r *http.Request) {
-        handlers.PlayHandler(config.OwnToneBaseURL, w, r)
+        handlers.PlayHandler(ownTone, w, r)
     })
     http.HandleFunc("/player/pause", func(w http.ResponseWriter, r *http.Request) {
-        handlers.PauseHandler(config.OwnToneBaseURL, w, r)
+        handlers.PauseHandler(ownTone, w, r)
+    })
+    http.HandleFunc("/player/next", func(w http.ResponseWriter, r *http.Request) {
+        handlers.NextHandler(ownTone, w, r)
+    })
+    http.HandleFunc("/player/previous", func(w http.ResponseWriter, r *http.Request) {
+        handlers.PreviousHandler(ownTone, w, r)
+    })
+    http.HandleFunc("/player/stop", func(w http.ResponseWriter, r *http.Request) {
+        handlers.StopHandler(ownTone, w, r)
+    })
+    http.HandleFunc("/player/toggle", func(w http.ResponseWriter, r *http.Request) {
+        handlers.ToggleHandler(ownTone, w, r)
+    })
+    http.HandleFunc("/player/seek", func(w http.ResponseWriter, r *http.Request) {
+        handlers.SeekHandler(ownTone, w, r)
+    })
+    http.HandleFunc("/player/shuffle", func(w http.ResponseWriter, r *http.Request) {
+        handlers.ShuffleHandler(ownTone, w, r)
+    })
+    http.HandleFunc("/player/repeat", func(w http.ResponseWriter, r *http.Request) {
+        handlers.RepeatHandler(ownTone, w, r)
+    })
+    http.HandleFunc("/player/consume", func(w http.ResponseWriter, r *http.Request) {
+        handlers.ConsumeHandler(ownTone, w, r)
+    })
+    http.HandleFunc("/player/volume", func(w http.ResponseWriter, r *http.Request) {
+        handlers.VolumeHandler(ownTone, w, r)
     })
 
     // Queue Management Endpoints
     http.HandleFunc("/player/queue/list", func(w http.ResponseWriter, r *http.Request) {
-        handlers.ListQueueHandler(config.OwnToneBaseURL, w, r)
+        handlers.ListQueueHandler(ownTone, w, r)
     })
 
     http.HandleFunc("/player/queue/clear", func(w http.ResponseWriter, r *http.Request) {
-        handlers.ClearQueueHandler(config.OwnToneBaseURL, w, r)
+        handlers.ClearQueueHandler(ownTone, w, r)
     })
 
     http.HandleFunc("/player/queue/add", func(w http.ResponseWriter, r *http.Request) {
-        handlers.AddToQueueHandler(config.OwnToneBaseURL, w, r)
+        handlers.AddToQueueHandler(ownTone, w, r)
+    })
+
+    http.HandleFunc("/player/queue/remove", func(w http.ResponseWriter, r *http.Request) {
+        handlers.RemoveQueueItemHandler(ownTone, w, r)
+    })
+
+    http.HandleFunc("/player/queue/move", func(w http.ResponseWriter, r *http.Request) {
+        handlers.MoveQueueItemHandler(ownTone, w, r)
+    })
+
+    http.HandleFunc("/player/queue/insert", func(w http.ResponseWriter, r *http.Request) {
+        handlers.InsertIntoQueueHandler(ownTone, w, r)
+    })
+
+    http.HandleFunc("/player/queue/play", func(w http.ResponseWriter, r *http.Request) {
+        handlers.PlayQueueItemHandler(ownTone, w, r)
+    })
+
+    // Set up HTTP routes for output management
+    http.HandleFunc("/outputs/list", func(w http.ResponseWriter, r *http.Request) {
+        handlers.ListOutputsHandler(ownTone, w, r)
+    })
+    http.HandleFunc("/outputs/update", func(w http.ResponseWriter, r *http.Request) {
+        handlers.UpdateOutputHandler(ownTone, w, r)
+    })
+    http.HandleFunc("/outputs/select", func(w http.ResponseWriter, r *http.Request) {
+        handlers.SelectOutputsHandler(ownTone, w, r)
+    })
+
+    // Set up HTTP routes for library browsing
+    http.HandleFunc("/library/search", func(w http.ResponseWriter, r *http.Request) {
+        handlers.SearchLibraryHandler(ownTone, w, r)
+    })
+    http.HandleFunc("/library/artist-albums", func(w http.ResponseWriter, r *http.Request) {
+        handlers.ArtistAlbumsHandler(ownTone, w, r)
+    })
+    http.HandleFunc("/library/album-tracks", func(w http.ResponseWriter, r *http.Request) {
+        handlers.AlbumTracksHandler(ownTone, w, r)
+    })
+
+    // Set up HTTP routes for playlist management
+    http.HandleFunc("/playlists/list", func(w http.ResponseWriter, r *http.Request) {
+        handlers.ListPlaylistsHandler(pocketBase, w, r)
+    })
+    http.HandleFunc("/playlists/create", func(w http.ResponseWriter, r *http.Request) {
+        handlers.CreatePlaylistHandler(pocketBase, w, r)
+    })
+    http.HandleFunc("/playlists/update", func(w http.ResponseWriter, r *http.Request) {
+        handlers.UpdatePlaylistHandler(pocketBase, w, r)
+    })
+    http.HandleFunc("/playlists/delete", func(w http.ResponseWriter, r *http.Request) {
+        handlers.DeletePlaylistHandler(pocketBase, w, r)
     })
 
     // Set up HTTP routes for cards management
     http.HandleFunc("/cards/associate", func(w http.ResponseWriter, r *http.Request) {
-        handlers.AssociateCardHandler(cardDetectedChan, modeSwitch, config.PocketBaseURL, w, r)
+        handlers.AssociateCardHandler(cardDetectedChan, modeSwitch, pocketBase, w, r)
+    })
+    http.HandleFunc("/cards/list", func(w http.ResponseWriter, r *http.Request) {
+        handlers.ListCardsHandler(pocketBase, w, r)
+    })
+    http.HandleFunc("/cards/get", func(w http.ResponseWriter, r *http.Request) {
+        handlers.GetCardHandler(pocketBase, w, r)
+    })
+    http.HandleFunc("/cards/update", func(w http.ResponseWriter, r *http.Request) {
+        handlers.UpdateCardHandler(pocketBase, w, r)
+    })
+    http.HandleFunc("/cards/delete", func(w http.ResponseWriter, r *http.Request) {
+        handlers.DeleteCardHandler(pocketBase, w, r)
     })
 
     // Set up HTTP routes for alarm management
     http.HandleFunc("/alarms/create", func(w http.ResponseWriter, r *http.Request) {
-        handlers.CreateAlarmHandler(config.PocketBaseURL, w, r)
+        handlers.CreateAlarmHandler(pocketBase, w, r)
     })
     http.HandleFunc("/alarms/delete", func(w http.ResponseWriter, r *http.Request) {
-        handlers.DeleteAlarmHandler(config.PocketBaseURL, w, r)
+        handlers.DeleteAlarmHandler(pocketBase, w, r)
     })
     http.HandleFunc("/alarms/list", func(w http.ResponseWriter, r *http.Request) {
-        handlers.ListAlarmsHandler(config.PocketBaseURL, w, r)
+        handlers.ListAlarmsHandler(pocketBase, alarmChecker, w, r)
+    })
+    http.HandleFunc("/alarms/next", func(w http.ResponseWriter, r *http.Request) {
+        handlers.NextAlarmHandler(alarmChecker, w, r)
     })
     http.HandleFunc("/alarms/set-status", func(w http.ResponseWriter, r *http.Request) {
-        handlers.SetAlarmStatusHandler(config.PocketBaseURL, w, r)
+        handlers.SetAlarmStatusHandler(pocketBase, w, r)
     })
     http.HandleFunc("/alarms/change-playlist", func(w http.ResponseWriter, r *http.Request) {
-        handlers.ChangeAlarmPlaylistHandler(config.PocketBaseURL, w, r)
+        handlers.ChangeAlarmPlaylistHandler(pocketBase, w, r)
     })
     http.HandleFunc("/alarms/change-hour", func(w http.ResponseWriter, r *http.Request) {
-        handlers.ChangeAlarmHourHandler(config.PocketBaseURL, w, r)
+        handlers.ChangeAlarmHourHandler(pocketBase, w, r)
+    })
+    http.HandleFunc("/alarms/change-schedule", func(w http.ResponseWriter, r *http.Request) {
+        handlers.ChangeAlarmScheduleHandler(pocketBase, w, r)
+    })
+    http.HandleFunc("/alarms/change-volume", func(w http.ResponseWriter, r *http.Request) {
+        handlers.ChangeAlarmVolumeHandler(pocketBase, w, r)
+    })
+    http.HandleFunc("/alarms/results", func(w http.ResponseWriter, r *http.Request) {
+        handlers.AlarmResultsHandler(alarmChecker, w, r)
+    })
+    http.HandleFunc("/alarms/snooze", func(w http.ResponseWriter, r *http.Request) {
+        handlers.SnoozeAlarmHandler(alarmChecker, w, r)
+    })
+    http.HandleFunc("/alarms/stop", func(w http.ResponseWriter, r *http.Request) {
+        handlers.StopAlarmHandler(alarmChecker, w, r)
     })
 
     // Start the HTTP server
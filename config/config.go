@@ -4,7 +4,12 @@ import (
 	"encoding/json"
 	"fmt"
 	"os"
+	"time"
 
+	"cartophone-server/internal/alarms"
+	"cartophone-server/internal/constants"
+	"cartophone-server/internal/pocketbase"
+	"cartophone-server/internal/sleeptimer"
 	"cartophone-server/internal/utils"
 )
 
@@ -13,6 +18,116 @@ type Config struct {
 	DevicePath     string `json:"device_path"`
 	PocketBaseURL  string `json:"pocket_base_url"`
 	OwnToneBaseURL string `json:"owntone_base_url"` // Added OwnTone base URL
+	AlarmVolume    int    `json:"alarm_volume"`     // Wake-up volume (0-100)
+	SnoozeMinutes  int    `json:"snooze_minutes"`   // How long a snoozed alarm stays silent
+
+	// IANA timezone of alarms without their own, such as "Europe/Paris".
+	// Empty uses the timezone of the server.
+	Timezone string `json:"timezone"`
+
+	// How long alarms take to fade in to their volume, a negative value disables it
+	AlarmFadeInSeconds int `json:"alarm_fade_in_seconds"`
+
+	// Duration of sleep timers started without one, such as with a card
+	SleepTimerMinutes int `json:"sleep_timer_minutes"`
+	// How long the volume takes to go down when the sleep timer expires
+	SleepTimerFadeOutSeconds int `json:"sleep_timer_fade_out_seconds"`
+
+	// What scanning any card does while an alarm rings: "snooze", "stop" or "none"
+	AlarmCardAction string `json:"alarm_card_action"`
+	// How long an alarm nobody stops rings before it stops by itself
+	AlarmMaxRingMinutes int `json:"alarm_max_ring_minutes"`
+
+	// PocketBase authentication, requests are anonymous when no identity is set.
+	// The collection is "_superusers" for admins (PocketBase >= 0.23), "admins"
+	// for admins on older versions, or any auth collection such as "users".
+	PocketBaseAuthCollection string `json:"pocket_base_auth_collection"`
+	PocketBaseIdentity       string `json:"pocket_base_identity"`
+	PocketBasePassword       string `json:"pocket_base_password"`
+	PocketBaseTimeoutMs      int    `json:"pocket_base_timeout_ms"`
+
+	// Timeout of requests to the OwnTone API
+	OwnToneTimeoutMs int `json:"owntone_timeout_ms"`
+
+	// Time a card must be off the reader before it counts as removed and can trigger again
+	CardRetriggerWindowMs int `json:"card_retrigger_window_ms"`
+}
+
+// Defaults used when a value is missing from the configuration file
+const (
+	DefaultAlarmVolume              = 50
+	DefaultSnoozeMinutes            = 9
+	DefaultAlarmFadeInSeconds       = 60
+	DefaultAlarmCardAction          = constants.AlarmCardStop
+	DefaultAlarmMaxRingMinutes      = 60
+	DefaultSleepTimerMinutes        = 30
+	DefaultSleepTimerFadeOutSeconds = 30
+	DefaultCardRetriggerWindowMs    = 3000
+	DefaultOwnToneTimeoutMs         = 10000
+	DefaultPocketBaseTimeoutMs      = 10000
+	DefaultPocketBaseCollection     = "_superusers"
+)
+
+// PocketBaseTimeout returns the PocketBase request timeout as a duration
+func (c *Config) PocketBaseTimeout() time.Duration {
+	return time.Duration(c.PocketBaseTimeoutMs) * time.Millisecond
+}
+
+// PocketBaseCredentials returns the PocketBase credentials, or nil when none are configured
+func (c *Config) PocketBaseCredentials() *pocketbase.Credentials {
+	if c.PocketBaseIdentity == "" {
+		return nil
+	}
+	return &pocketbase.Credentials{
+		Collection: c.PocketBaseAuthCollection,
+		Identity:   c.PocketBaseIdentity,
+		Password:   c.PocketBasePassword,
+	}
+}
+
+// OwnToneTimeout returns the OwnTone request timeout as a duration
+func (c *Config) OwnToneTimeout() time.Duration {
+	return time.Duration(c.OwnToneTimeoutMs) * time.Millisecond
+}
+
+// Location returns the configured timezone, or the server's one when none is set
+func (c *Config) Location() *time.Location {
+	if c.Timezone == "" {
+		return time.Local
+	}
+	loc, err := time.LoadLocation(c.Timezone)
+	if err != nil {
+		// Checked by LoadConfig
+		return time.Local
+	}
+	return loc
+}
+
+// AlarmSettings returns how alarms ring
+func (c *Config) AlarmSettings() alarms.Settings {
+	settings := alarms.Settings{
+		Volume:     c.AlarmVolume,
+		Snooze:     time.Duration(c.SnoozeMinutes) * time.Minute,
+		MaxRinging: time.Duration(c.AlarmMaxRingMinutes) * time.Minute,
+		Location:   c.Location(),
+	}
+	if c.AlarmFadeInSeconds > 0 {
+		settings.FadeIn = time.Duration(c.AlarmFadeInSeconds) * time.Second
+	}
+	return settings
+}
+
+// SleepTimerSettings returns how the sleep timer behaves
+func (c *Config) SleepTimerSettings() sleeptimer.Settings {
+	return sleeptimer.Settings{
+		Duration: time.Duration(c.SleepTimerMinutes) * time.Minute,
+		FadeOut:  time.Duration(c.SleepTimerFadeOutSeconds) * time.Second,
+	}
+}
+
+// CardRetriggerWindow returns the card re-trigger window as a duration
+func (c *Config) CardRetriggerWindow() time.Duration {
+	return time.Duration(c.CardRetriggerWindowMs) * time.Millisecond
 }
 
 // LoadConfig loads configuration from a JSON file
@@ -36,11 +151,69 @@ func LoadConfig(filePath string) (*Config, error) {
 		return nil, fmt.Errorf("failed to decode config file: %w", err)
 	}
 
+	if config.Timezone != "" {
+		if _, err := time.LoadLocation(config.Timezone); err != nil {
+			utils.LogMessage("CONFIG", "Invalid timezone in config file", map[string]interface{}{
+				"timezone": config.Timezone,
+				"error":    err.Error(),
+			})
+			return nil, fmt.Errorf("invalid timezone %q: %w", config.Timezone, err)
+		}
+	}
+
+	if config.AlarmVolume <= 0 || config.AlarmVolume > 100 {
+		config.AlarmVolume = DefaultAlarmVolume
+	}
+	if config.AlarmFadeInSeconds == 0 {
+		config.AlarmFadeInSeconds = DefaultAlarmFadeInSeconds
+	}
+	if config.SnoozeMinutes <= 0 {
+		config.SnoozeMinutes = DefaultSnoozeMinutes
+	}
+	if config.SleepTimerMinutes <= 0 {
+		config.SleepTimerMinutes = DefaultSleepTimerMinutes
+	}
+	if config.SleepTimerFadeOutSeconds <= 0 {
+		config.SleepTimerFadeOutSeconds = DefaultSleepTimerFadeOutSeconds
+	}
+	switch config.AlarmCardAction {
+	case constants.AlarmCardSnooze, constants.AlarmCardStop, constants.AlarmCardNone:
+	default:
+		config.AlarmCardAction = DefaultAlarmCardAction
+	}
+	if config.AlarmMaxRingMinutes <= 0 {
+		config.AlarmMaxRingMinutes = DefaultAlarmMaxRingMinutes
+	}
+	if config.PocketBaseTimeoutMs <= 0 {
+		config.PocketBaseTimeoutMs = DefaultPocketBaseTimeoutMs
+	}
+	if config.PocketBaseAuthCollection == "" {
+		config.PocketBaseAuthCollection = DefaultPocketBaseCollection
+	}
+	if config.OwnToneTimeoutMs <= 0 {
+		config.OwnToneTimeoutMs = DefaultOwnToneTimeoutMs
+	}
+	if config.CardRetriggerWindowMs <= 0 {
+		config.CardRetriggerWindowMs = DefaultCardRetriggerWindowMs
+	}
+
 	// Log loaded configuration
 	utils.LogMessage("CONFIG", "Configuration loaded successfully", map[string]interface{}{
-		"devicePath":     config.DevicePath,
-		"pocketBaseURL":  config.PocketBaseURL,
-		"ownToneBaseURL": config.OwnToneBaseURL,
+		"devicePath":               config.DevicePath,
+		"pocketBaseURL":            config.PocketBaseURL,
+		"pocketBaseIdentity":       config.PocketBaseIdentity,
+		"pocketBaseTimeoutMs":      config.PocketBaseTimeoutMs,
+		"ownToneBaseURL":           config.OwnToneBaseURL,
+		"ownToneTimeoutMs":         config.OwnToneTimeoutMs,
+		"timezone":                 config.Timezone,
+		"alarmVolume":              config.AlarmVolume,
+		"alarmFadeInSeconds":       config.AlarmFadeInSeconds,
+		"snoozeMinutes":            config.SnoozeMinutes,
+		"alarmCardAction":          config.AlarmCardAction,
+		"alarmMaxRingMinutes":      config.AlarmMaxRingMinutes,
+		"sleepTimerMinutes":        config.SleepTimerMinutes,
+		"sleepTimerFadeOutSeconds": config.SleepTimerFadeOutSeconds,
+		"cardRetriggerWindowMs":    config.CardRetriggerWindowMs,
 	})
 
 	return &config, nil
@@ -1,43 +1,301 @@
 package alarms
 
 import (
+	"context"
 	"fmt"
+	"sync"
 	"time"
 
+	"cartophone-server/internal/owntone"
 	"cartophone-server/internal/pocketbase"
 	"cartophone-server/internal/utils"
 )
 
+// maxResults is the number of alarm results kept in memory
+const maxResults = 50
+
+// fadeInStartVolume is the volume a fading alarm starts playing at
+const fadeInStartVolume = 5
+
+// maxCatchUpMinutes is how many missed minutes are still checked after a
+// system suspend, or once the alarms are first loaded after the checker
+// started. Older alarms are skipped.
+const maxCatchUpMinutes = 10
+
+// pollInterval is the longest the checker sleeps between two checks, so that
+// it notices wall-clock jumps and resumes from suspend quickly
+const pollInterval = 15 * time.Second
+
+// Result records what happened when an alarm fired
+type Result struct {
+	AlarmID      string    `json:"alarmId"`
+	PlaylistID   string    `json:"playlistId"`
+	PlaylistName string    `json:"playlistName,omitempty"`
+	URI          string    `json:"uri,omitempty"`
+	Volume       int       `json:"volume"`
+	FiredAt      time.Time `json:"firedAt"`
+	Rang         bool      `json:"rang"`
+	Deactivated  bool      `json:"deactivated,omitempty"`
+	Error        string    `json:"error,omitempty"`
+}
+
+// Settings configures how alarms ring
+type Settings struct {
+	Volume int           // Wake-up volume (0-100) of alarms without their own
+	FadeIn time.Duration // Fade-in duration of alarms without their own, 0 disables it
+	Snooze time.Duration // How long a snoozed alarm stays silent
+
+	// How long an alarm rings before it stops by itself, 0 lets it ring
+	// until the playlist ends
+	MaxRinging time.Duration
+
+	Location *time.Location   // Timezone of alarms without their own, nil is the local one
+	Clock    func() time.Time // Returns the current time, nil uses time.Now
+}
+
+// location returns the timezone of alarms without their own
+func (s Settings) location() *time.Location {
+	if s.Location == nil {
+		return time.Local
+	}
+	return s.Location
+}
+
+// volume returns the volume an alarm fades in to
+func (s Settings) volume(alarm pocketbase.Alarm) int {
+	if alarm.Volume > 0 && alarm.Volume <= 100 {
+		return alarm.Volume
+	}
+	return s.Volume
+}
+
+// fadeIn returns how long an alarm takes to reach its volume
+func (s Settings) fadeIn(alarm pocketbase.Alarm) time.Duration {
+	switch {
+	case alarm.FadeInSeconds < 0:
+		return 0
+	case alarm.FadeInSeconds > 0:
+		return time.Duration(alarm.FadeInSeconds) * time.Second
+	default:
+		return s.FadeIn
+	}
+}
+
+// Checker periodically checks for active alarms and plays their playlist on
+// Owntone. It keeps the alarms in memory so that they ring even when
+// PocketBase cannot be reached.
+type Checker struct {
+	pocketBase *pocketbase.Client
+	ownTone    *owntone.Client
+	settings   Settings
+
+	// Only used by the checker goroutine
+	lastChecked time.Time                  // Last minute whose alarms were fired
+	fired       map[string]firedOccurrence // Occurrence each alarm last fired for
+
+	locMu     sync.Mutex
+	locations map[string]*time.Location // Loaded alarm timezones by name
+
+	scheduleMu sync.Mutex
+	schedule   map[string]pocketbase.Alarm // Alarms by ID, nil until first loaded
+
+	mu      sync.Mutex
+	results []Result
+
+	ringMu  sync.Mutex
+	ringing *ringingAlarm
+}
+
 // StartAlarmChecker starts a goroutine to periodically check for active alarms.
-func StartAlarmChecker(baseURL string) {
-	go func() {
-		for {
-			now := time.Now()
-			currentTime := fmt.Sprintf("%02d:%02d", now.Hour(), now.Minute())
-
-			// Fetch active alarms for the current time
-			alarms, err := pocketbase.FetchActiveAlarms(baseURL, currentTime)
-			if err != nil {
-				utils.LogMessage("ERROR", "Failed to fetch activated alarms", err.Error())
-				time.Sleep(1 * time.Minute) // Retry after a minute
-				continue
-			}
-
-			// Process each active alarm
-			for _, alarm := range alarms {
-				playlist, err := pocketbase.GetPlaylist(baseURL, alarm.PlaylistID)
-				if err != nil {
-					utils.LogMessage("ERROR", fmt.Sprintf("Failed to fetch playlist for alarm %s", alarm.ID), err.Error())
-					continue
-				}
-
-				// Simulate playing the playlist
-				message := fmt.Sprintf("Playing playlist '%s' (URI: %s) for alarm %s", playlist.Name, playlist.URI, alarm.ID)
-				utils.LogMessage("ALARM", message, nil)
-			}
-
-			// Wait a minute before checking again
-			time.Sleep(1 * time.Minute)
+func StartAlarmChecker(pocketBase *pocketbase.Client, ownTone *owntone.Client, settings Settings) *Checker {
+	checker := newChecker(pocketBase, ownTone, settings)
+
+	go checker.syncAlarms()
+	go checker.run()
+
+	return checker
+}
+
+// newChecker creates a checker that has not checked any alarm yet
+func newChecker(pocketBase *pocketbase.Client, ownTone *owntone.Client, settings Settings) *Checker {
+	checker := &Checker{
+		pocketBase: pocketBase,
+		ownTone:    ownTone,
+		settings:   settings,
+		fired:      make(map[string]firedOccurrence),
+		locations:  make(map[string]*time.Location),
+	}
+
+	// Catch up from the minute the checker started, so that alarms due
+	// while the alarms are first loaded still ring
+	checker.lastChecked = checker.now().Round(0).Truncate(time.Minute).Add(-time.Minute)
+	return checker
+}
+
+// now returns the current time from the configured clock
+func (c *Checker) now() time.Time {
+	if c.settings.Clock != nil {
+		return c.settings.Clock()
+	}
+	return time.Now()
+}
+
+// run checks the alarms at the start of every wall-clock minute
+func (c *Checker) run() {
+	for {
+		// Strip the monotonic reading, minutes follow the wall clock
+		now := c.now().Round(0)
+		c.checkMinutes(now)
+		c.CheckRinging(context.Background())
+
+		wait := now.Truncate(time.Minute).Add(time.Minute).Sub(now)
+		if wait > pollInterval {
+			wait = pollInterval
 		}
-	}()
-}
\ No newline at end of file
+		time.Sleep(wait)
+	}
+}
+
+// checkMinutes fires the alarms of every minute since the last checked one
+// up to the minute of now. Until the alarms are loaded from PocketBase, the
+// minutes are checked again on the next call.
+func (c *Checker) checkMinutes(now time.Time) {
+	current := now.Truncate(time.Minute)
+
+	from := c.lastChecked.Add(time.Minute)
+	switch {
+	case current.Before(c.lastChecked):
+		// The clock went back: the fired alarms are remembered, so going
+		// over a minute again does not ring them twice
+		from = current
+	case current.Before(from):
+		return
+	case current.Sub(from) >= maxCatchUpMinutes*time.Minute:
+		skippedUntil := current.Add(-maxCatchUpMinutes * time.Minute)
+		utils.LogMessage("ERROR", "Skipping alarms missed for too long", map[string]interface{}{
+			"from":  from,
+			"until": skippedUntil,
+		})
+		from = skippedUntil.Add(time.Minute)
+	}
+
+	alarms, ok := c.activatedAlarms()
+	if !ok {
+		return
+	}
+
+	for minute := from; !minute.After(current); minute = minute.Add(time.Minute) {
+		c.checkMinute(alarms, minute, now)
+		c.lastChecked = minute
+	}
+
+	for id, fired := range c.fired {
+		if current.Sub(fired.at) > firedRetention {
+			delete(c.fired, id)
+		}
+	}
+}
+
+// checkMinute fires the alarms due in minute that did not fire for the same occurrence yet
+func (c *Checker) checkMinute(alarms []pocketbase.Alarm, minute, now time.Time) {
+	for _, alarm := range alarms {
+		occurrence, due := dueOccurrence(alarm, minute, c.location(alarm))
+		if !due {
+			continue
+		}
+		if fired, ok := c.fired[alarm.ID]; ok && fired.occurrence == occurrence {
+			continue
+		}
+
+		c.fired[alarm.ID] = firedOccurrence{occurrence: occurrence, at: minute}
+		if minute.Before(now.Truncate(time.Minute)) {
+			utils.LogMessage("ALARM", fmt.Sprintf("Alarm %s fires late", alarm.ID), map[string]interface{}{"scheduledFor": occurrence})
+		}
+		c.fire(alarm, now)
+	}
+}
+
+// Results returns the most recent alarm results, oldest first
+func (c *Checker) Results() []Result {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	results := make([]Result, len(c.results))
+	copy(results, c.results)
+	return results
+}
+
+// fire plays the playlist of an alarm and records the result
+func (c *Checker) fire(alarm pocketbase.Alarm, now time.Time) {
+	result := Result{
+		AlarmID:    alarm.ID,
+		PlaylistID: alarm.PlaylistID,
+		FiredAt:    now,
+	}
+
+	if err := c.ring(alarm, &result); err != nil {
+		result.Error = err.Error()
+		utils.LogMessage("ERROR", fmt.Sprintf("Alarm %s failed to ring", alarm.ID), result)
+	} else {
+		result.Rang = true
+		utils.LogMessage("ALARM", fmt.Sprintf("Alarm %s is ringing", alarm.ID), result)
+		c.startRinging(alarm, now, result.Volume, c.settings.fadeIn(alarm))
+	}
+
+	// One-shot and dated alarms are deactivated even if they failed to ring,
+	// so that they never go off on another day
+	if alarm.FiresOnce() {
+		c.deactivateInMemory(alarm.ID)
+		if err := c.pocketBase.SetAlarmStatus(context.Background(), alarm.ID, false); err != nil {
+			utils.LogMessage("ERROR", fmt.Sprintf("Failed to deactivate one-shot alarm %s", alarm.ID), err.Error())
+		} else {
+			result.Deactivated = true
+		}
+	}
+
+	c.mu.Lock()
+	c.results = append(c.results, result)
+	if len(c.results) > maxResults {
+		c.results = c.results[len(c.results)-maxResults:]
+	}
+	c.mu.Unlock()
+}
+
+// ring replaces the Owntone queue with the alarm playlist and starts playback,
+// at a low volume when the alarm fades in
+func (c *Checker) ring(alarm pocketbase.Alarm, result *Result) error {
+	ctx := context.Background()
+
+	playlist, err := c.pocketBase.GetPlaylist(ctx, alarm.PlaylistID)
+	if err != nil {
+		return fmt.Errorf("failed to fetch playlist: %w", err)
+	}
+	result.PlaylistName = playlist.Name
+	result.URI = playlist.URI
+	result.Volume = c.settings.volume(alarm)
+
+	// The alarm still rings on the selected outputs when the playlist ones are unavailable
+	if len(playlist.Outputs) > 0 {
+		if err := c.ownTone.SelectOutputs(ctx, playlist.Outputs); err != nil {
+			utils.LogMessage("ERROR", fmt.Sprintf("Failed to select outputs for alarm %s", alarm.ID), err.Error())
+		}
+	}
+	if err := c.ownTone.ClearQueue(ctx); err != nil {
+		return fmt.Errorf("failed to clear queue: %w", err)
+	}
+	if err := c.ownTone.AddToQueue(ctx, playlist.QueueURIs()); err != nil {
+		return fmt.Errorf("failed to add playlist to queue: %w", err)
+	}
+	volume := result.Volume
+	if c.settings.fadeIn(alarm) > 0 {
+		volume = fadeInStartVolume
+	}
+	if err := c.ownTone.SetVolume(ctx, volume); err != nil {
+		return fmt.Errorf("failed to set volume: %w", err)
+	}
+	if err := c.ownTone.PlayQueue(ctx, playlist.StartPosition, playlist.StartOffsetMs, playlist.Shuffle, playlist.Repeat); err != nil {
+		return fmt.Errorf("failed to start playback: %w", err)
+	}
+
+	return nil
+}
@@ -0,0 +1,312 @@
+package alarms
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"cartophone-server/internal/owntone"
+	"cartophone-server/internal/pocketbase"
+	"cartophone-server/internal/utils"
+)
+
+// ErrNotRinging is returned when snoozing or stopping while no alarm is ringing
+var ErrNotRinging = errors.New("no alarm is ringing")
+
+// Ringing describes the alarm that is currently ringing or snoozed
+type Ringing struct {
+	AlarmID      string     `json:"alarmId"`
+	PlaylistID   string     `json:"playlistId"`
+	Since        time.Time  `json:"since"`
+	Snoozed      bool       `json:"snoozed"`
+	SnoozedUntil *time.Time `json:"snoozedUntil,omitempty"`
+}
+
+// ringingAlarm is the in-memory state of the ringing alarm
+type ringingAlarm struct {
+	Ringing
+	volume     int                // Volume the alarm fades in to
+	fadeIn     time.Duration      // Fade-in duration, 0 when the alarm rings at full volume
+	cancelFade context.CancelFunc // Stops the running fade-in
+	fadeDone   chan struct{}      // Closed once the running fade-in returned
+	timer      *time.Timer        // Rings the alarm again at the end of a snooze
+	rangAt     time.Time          // When the alarm last started playing, after a snooze too
+	restarting bool               // The player is being restarted at the end of a snooze
+	silenced   bool               // Stop paused the player, or is about to
+}
+
+// startRinging marks an alarm as ringing, replacing any previous ringing
+// alarm, and starts fading it in
+func (c *Checker) startRinging(alarm pocketbase.Alarm, now time.Time, volume int, fadeIn time.Duration) {
+	c.ringMu.Lock()
+	defer c.ringMu.Unlock()
+
+	c.clearRinging()
+	c.ringing = &ringingAlarm{
+		Ringing: Ringing{
+			AlarmID:    alarm.ID,
+			PlaylistID: alarm.PlaylistID,
+			Since:      now,
+		},
+		volume: volume,
+		fadeIn: fadeIn,
+		rangAt: now,
+	}
+	c.startFade(c.ringing)
+}
+
+// Ringing returns the alarm currently ringing or snoozed, or nil
+func (c *Checker) Ringing() *Ringing {
+	c.ringMu.Lock()
+	defer c.ringMu.Unlock()
+
+	if c.ringing == nil {
+		return nil
+	}
+	ringing := c.ringing.Ringing
+	return &ringing
+}
+
+// IsRinging reports whether an alarm is ringing or snoozed
+func (c *Checker) IsRinging() bool {
+	return c.Ringing() != nil
+}
+
+// CheckRinging forgets the ringing alarm once the player stopped playing it,
+// such as when its playlist ended or it was paused outside the checker, and
+// stops it once it rang for longer than the configured maximum. It reports
+// whether an alarm is still ringing or snoozed.
+func (c *Checker) CheckRinging(ctx context.Context) bool {
+	c.ringMu.Lock()
+	ringing := c.ringing
+	if ringing == nil || ringing.Snoozed || ringing.restarting {
+		c.ringMu.Unlock()
+		return ringing != nil
+	}
+	if c.settings.MaxRinging > 0 && c.now().Sub(ringing.rangAt) >= c.settings.MaxRinging {
+		ringing.silenced = true
+		fadeDone := c.clearRinging()
+		c.ringMu.Unlock()
+
+		waitFade(fadeDone)
+		if err := c.ownTone.Pause(ctx); err != nil {
+			utils.LogMessage("ERROR", "Failed to stop alarm after its maximum ringing time", err.Error())
+		}
+		utils.LogMessage("ALARM", "Alarm stopped after its maximum ringing time", ringing.Ringing)
+		return false
+	}
+	c.ringMu.Unlock()
+
+	status, err := c.ownTone.GetPlayerStatus(ctx)
+	if err != nil {
+		utils.LogMessage("ERROR", "Failed to check whether the alarm is still playing", err.Error())
+		return true
+	}
+	if status.State == owntone.StatePlay {
+		return true
+	}
+
+	c.ringMu.Lock()
+	defer c.ringMu.Unlock()
+
+	// Snoozed, stopped or replaced while the player was asked
+	if c.ringing != ringing || ringing.Snoozed || ringing.restarting {
+		return c.ringing != nil
+	}
+	c.clearRinging()
+	utils.LogMessage("ALARM", "Alarm no longer playing, forgotten", map[string]interface{}{
+		"alarmId": ringing.AlarmID,
+		"state":   status.State,
+	})
+	return false
+}
+
+// Snooze pauses the ringing alarm and rings it again after the snooze duration
+func (c *Checker) Snooze(ctx context.Context) (*Ringing, error) {
+	// The player is only called without holding c.ringMu, so that a slow
+	// Owntone does not block the card reader and the checker
+	c.ringMu.Lock()
+	ringing := c.ringing
+	if ringing == nil {
+		c.ringMu.Unlock()
+		return nil, ErrNotRinging
+	}
+	fadeDone := c.stopFade(ringing)
+	until := c.now().Add(c.settings.Snooze)
+	ringing.Snoozed = true
+	ringing.SnoozedUntil = &until
+	if ringing.timer != nil {
+		ringing.timer.Stop()
+	}
+	ringing.timer = time.AfterFunc(c.settings.Snooze, func() {
+		c.endSnooze(ringing)
+	})
+	snoozed := ringing.Ringing
+	c.ringMu.Unlock()
+
+	waitFade(fadeDone)
+	if err := c.ownTone.Pause(ctx); err != nil {
+		// Keep ringing, the alarm can be snoozed or stopped again
+		c.ringMu.Lock()
+		if c.ringing == ringing && ringing.Snoozed {
+			ringing.timer.Stop()
+			ringing.timer = nil
+			ringing.Snoozed = false
+			ringing.SnoozedUntil = nil
+		}
+		c.ringMu.Unlock()
+		return nil, err
+	}
+
+	utils.LogMessage("ALARM", "Alarm snoozed", snoozed)
+	return &snoozed, nil
+}
+
+// Stop silences the ringing alarm for good
+func (c *Checker) Stop(ctx context.Context) (*Ringing, error) {
+	c.ringMu.Lock()
+	ringing := c.ringing
+	if ringing == nil {
+		c.ringMu.Unlock()
+		return nil, ErrNotRinging
+	}
+	ringing.silenced = true
+	fadeDone := c.clearRinging()
+	c.ringMu.Unlock()
+
+	// A volume change still on its way must not turn the alarm back up
+	waitFade(fadeDone)
+	if err := c.ownTone.Pause(ctx); err != nil {
+		// Keep ringing unless another alarm replaced it, so that stopping
+		// can be tried again
+		c.ringMu.Lock()
+		if c.ringing == nil {
+			ringing.silenced = false
+			c.ringing = ringing
+		}
+		c.ringMu.Unlock()
+		return nil, err
+	}
+
+	utils.LogMessage("ALARM", "Alarm stopped", ringing.Ringing)
+	return &ringing.Ringing, nil
+}
+
+// Dismiss forgets the ringing alarm without touching the player, for
+// instance when a card replaced the alarm playlist
+func (c *Checker) Dismiss() {
+	c.ringMu.Lock()
+	defer c.ringMu.Unlock()
+
+	if c.ringing != nil {
+		utils.LogMessage("ALARM", "Alarm dismissed", c.ringing.Ringing)
+		c.clearRinging()
+	}
+}
+
+// endSnooze rings the alarm again once its snooze is over
+func (c *Checker) endSnooze(ringing *ringingAlarm) {
+	c.ringMu.Lock()
+	// The alarm was stopped, dismissed or replaced in the meantime
+	if c.ringing != ringing || !ringing.Snoozed {
+		c.ringMu.Unlock()
+		return
+	}
+	ringing.Snoozed = false
+	ringing.SnoozedUntil = nil
+	ringing.timer = nil
+	ringing.restarting = true
+	ringing.rangAt = c.now()
+	volume := ringing.volume
+	if ringing.fadeIn > 0 {
+		volume = fadeInStartVolume
+	}
+	c.ringMu.Unlock()
+
+	ctx := context.Background()
+	if err := c.ownTone.SetVolume(ctx, volume); err != nil {
+		utils.LogMessage("ERROR", "Failed to set volume after snooze", err.Error())
+	}
+	if err := c.ownTone.Play(ctx); err != nil {
+		utils.LogMessage("ERROR", "Failed to ring alarm again after snooze", err.Error())
+	}
+
+	c.ringMu.Lock()
+	ringing.restarting = false
+	// Stopped or snoozed again while the player was restarting, which may
+	// have undone the pause
+	paused := ringing.silenced || ringing.Snoozed
+	if c.ringing == ringing && !paused {
+		c.startFade(ringing)
+	}
+	rang := ringing.Ringing
+	c.ringMu.Unlock()
+
+	if paused {
+		if err := c.ownTone.Pause(ctx); err != nil {
+			utils.LogMessage("ERROR", "Failed to pause alarm stopped after snooze", err.Error())
+		}
+		return
+	}
+	utils.LogMessage("ALARM", "Alarm ringing again after snooze", rang)
+}
+
+// startFade ramps the volume of a ringing alarm up to its target volume in
+// the background. The caller must hold c.ringMu.
+func (c *Checker) startFade(ringing *ringingAlarm) {
+	if ringing.fadeIn <= 0 {
+		return
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan struct{})
+	ringing.cancelFade = cancel
+	ringing.fadeDone = done
+
+	go func() {
+		defer close(done)
+		defer cancel()
+		err := c.ownTone.FadeVolume(ctx, fadeInStartVolume, ringing.volume, ringing.fadeIn)
+		if err != nil && ctx.Err() == nil {
+			utils.LogMessage("ERROR", "Failed to fade in alarm", map[string]interface{}{
+				"alarmId": ringing.AlarmID,
+				"error":   err.Error(),
+			})
+		}
+	}()
+}
+
+// stopFade stops the fade-in of a ringing alarm, leaving the volume where it
+// is. It returns the channel to pass to waitFade before pausing the player.
+// The caller must hold c.ringMu.
+func (c *Checker) stopFade(ringing *ringingAlarm) <-chan struct{} {
+	done := ringing.fadeDone
+	if ringing.cancelFade != nil {
+		ringing.cancelFade()
+		ringing.cancelFade = nil
+		ringing.fadeDone = nil
+	}
+	return done
+}
+
+// waitFade waits until a stopped fade-in returned, so that none of its volume
+// changes is still on its way. It must be called without holding c.ringMu.
+func waitFade(done <-chan struct{}) {
+	if done != nil {
+		<-done
+	}
+}
+
+// clearRinging forgets the ringing alarm and returns the channel of its
+// stopped fade-in, see stopFade. The caller must hold c.ringMu.
+func (c *Checker) clearRinging() <-chan struct{} {
+	if c.ringing == nil {
+		return nil
+	}
+	done := c.stopFade(c.ringing)
+	if c.ringing.timer != nil {
+		c.ringing.timer.Stop()
+	}
+	c.ringing = nil
+	return done
+}
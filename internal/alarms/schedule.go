@@ -0,0 +1,182 @@
+package alarms
+
+import (
+	"time"
+
+	"cartophone-server/internal/pocketbase"
+	"cartophone-server/internal/utils"
+)
+
+// hourLayout is the layout of the Hour field of an alarm
+const hourLayout = "15:04"
+
+// occurrenceLayout formats the local date and time an alarm rings at. It
+// sorts like the times it formats.
+const occurrenceLayout = pocketbase.DateLayout + " " + hourLayout
+
+// firedRetention is how long fired occurrences are remembered. It must be
+// longer than the hour repeated when DST ends, so that the repeated time does
+// not ring the alarm again.
+const firedRetention = 2 * time.Hour
+
+// firedOccurrence records the occurrence an alarm last fired for
+type firedOccurrence struct {
+	occurrence string    // Local date and time, see occurrenceLayout
+	at         time.Time // Minute it fired in
+}
+
+// dueOccurrence reports whether an alarm is due in the minute starting at
+// minute and returns the local date and time of that occurrence.
+//
+// An alarm is due in the minute the wall clock of its location reaches its
+// hour. A time skipped when DST starts is reached, and rings, right after the
+// clock jumped forward. A time repeated when DST ends is reached twice with
+// the same occurrence, which the checker fires only once.
+func dueOccurrence(alarm pocketbase.Alarm, minute time.Time, loc *time.Location) (string, bool) {
+	hour, err := time.Parse(hourLayout, alarm.Hour)
+	if err != nil {
+		return "", false
+	}
+
+	current := minute.In(loc)
+	previous := minute.Add(-time.Minute).In(loc)
+
+	date := current.Format(pocketbase.DateLayout)
+	occurrence := date + " " + hour.Format(hourLayout)
+	if previous.Format(occurrenceLayout) >= occurrence || current.Format(occurrenceLayout) < occurrence {
+		return "", false
+	}
+
+	if alarm.Date != "" && alarm.Date != date {
+		return "", false
+	}
+	if !alarm.RingsOnWeekday(current.Weekday()) {
+		return "", false
+	}
+
+	return occurrence, true
+}
+
+// location returns the location an alarm rings in: its own timezone when set
+// and valid, the configured one otherwise
+func (c *Checker) location(alarm pocketbase.Alarm) *time.Location {
+	if alarm.Timezone == "" {
+		return c.settings.location()
+	}
+
+	c.locMu.Lock()
+	defer c.locMu.Unlock()
+
+	if loc, ok := c.locations[alarm.Timezone]; ok {
+		return loc
+	}
+
+	loc, err := time.LoadLocation(alarm.Timezone)
+	if err != nil {
+		utils.LogMessage("ERROR", "Invalid alarm timezone, using the configured one", map[string]interface{}{
+			"alarmId":  alarm.ID,
+			"timezone": alarm.Timezone,
+			"error":    err.Error(),
+		})
+		loc = c.settings.location()
+	}
+	c.locations[alarm.Timezone] = loc
+	return loc
+}
+
+// NextAlarm is the alarm that rings next
+type NextAlarm struct {
+	Alarm      pocketbase.Alarm `json:"alarm"`
+	NextFireAt time.Time        `json:"nextFireAt"`
+	InSeconds  int              `json:"inSeconds"` // Time left until it rings
+}
+
+// NextFireAt returns when an alarm rings next, in its own or the configured timezone
+func (c *Checker) NextFireAt(alarm pocketbase.Alarm) (time.Time, bool) {
+	return NextFire(alarm, c.now(), c.location(alarm))
+}
+
+// Next returns the alarm in memory that rings next, or nil when none will.
+// It returns ErrNotLoaded until the alarms are first loaded from PocketBase.
+func (c *Checker) Next() (*NextAlarm, error) {
+	alarms, ok := c.activatedAlarms()
+	if !ok {
+		return nil, ErrNotLoaded
+	}
+	now := c.now()
+
+	var next *NextAlarm
+	for _, alarm := range alarms {
+		at, ok := NextFire(alarm, now, c.location(alarm))
+		if !ok || (next != nil && !at.Before(next.NextFireAt)) {
+			continue
+		}
+		next = &NextAlarm{
+			Alarm:      alarm,
+			NextFireAt: at,
+			InSeconds:  int(at.Sub(now).Seconds()),
+		}
+	}
+	return next, nil
+}
+
+// NextFire returns when an alarm rings next after the given time, following
+// the same rules as the checker. ok is false when the alarm is deactivated or
+// will not ring again.
+func NextFire(alarm pocketbase.Alarm, after time.Time, loc *time.Location) (next time.Time, ok bool) {
+	if !alarm.Activated {
+		return time.Time{}, false
+	}
+	hour, err := time.Parse(hourLayout, alarm.Hour)
+	if err != nil {
+		return time.Time{}, false
+	}
+
+	if alarm.Date != "" {
+		date, err := time.ParseInLocation(pocketbase.DateLayout, alarm.Date, loc)
+		if err != nil || !alarm.RingsOnWeekday(date.Weekday()) {
+			return time.Time{}, false
+		}
+		next = occurrenceTime(date, hour, loc)
+		return next, next.After(after)
+	}
+
+	// A week ahead covers every weekday mask
+	local := after.In(loc)
+	for days := 0; days <= 7; days++ {
+		date := time.Date(local.Year(), local.Month(), local.Day()+days, 12, 0, 0, 0, loc)
+		if !alarm.RingsOnWeekday(date.Weekday()) {
+			continue
+		}
+		if next = occurrenceTime(date, hour, loc); next.After(after) {
+			return next, true
+		}
+	}
+	return time.Time{}, false
+}
+
+// occurrenceTime returns the minute an alarm set for hour rings in on date,
+// see dueOccurrence: the first of two repeated times, or the end of the DST
+// gap for a skipped time.
+func occurrenceTime(date, hour time.Time, loc *time.Location) time.Time {
+	t := time.Date(date.Year(), date.Month(), date.Day(), hour.Hour(), hour.Minute(), 0, 0, loc)
+	wall := t.Format(occurrenceLayout)
+
+	// Repeated when DST ends, the earlier one rings
+	if earlier := t.Add(-time.Hour); earlier.Format(occurrenceLayout) == wall {
+		return earlier
+	}
+
+	// Skipped when DST starts: time.Date moved it out of the gap, the alarm
+	// rings at the first minute after the clock jumped over it
+	occurrence := date.Format(pocketbase.DateLayout) + " " + hour.Format(hourLayout)
+	if wall != occurrence {
+		for m := t.Add(-2 * time.Hour).Truncate(time.Minute); m.Before(t.Add(2 * time.Hour)); m = m.Add(time.Minute) {
+			if m.In(loc).Format(occurrenceLayout) >= occurrence {
+				return m
+			}
+		}
+	}
+
+	return t
+}
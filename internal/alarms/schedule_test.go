@@ -0,0 +1,178 @@
+package alarms
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"cartophone-server/internal/owntone"
+	"cartophone-server/internal/pocketbase"
+)
+
+// testClock is a settable clock for the checker
+type testClock struct {
+	now time.Time
+}
+
+func (c *testClock) Now() time.Time {
+	return c.now
+}
+
+// newTestChecker returns a checker ringing on a fake Owntone and PocketBase
+// server, in the Europe/Paris timezone, with the given alarms loaded
+func newTestChecker(t *testing.T, clock *testClock, alarms ...pocketbase.Alarm) *Checker {
+	t.Helper()
+
+	paris, err := time.LoadLocation("Europe/Paris")
+	if err != nil {
+		t.Skipf("Europe/Paris timezone not available: %v", err)
+	}
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if strings.HasPrefix(r.URL.Path, "/api/collections/playlists/") {
+			w.Header().Set("Content-Type", "application/json")
+			w.Write([]byte(`{"id":"playlist1","name":"Wake up","uri":"library:playlist:1"}`))
+			return
+		}
+		w.WriteHeader(http.StatusNoContent)
+	}))
+	t.Cleanup(server.Close)
+
+	c := newChecker(pocketbase.NewClient(server.URL, nil, nil), owntone.NewClient(server.URL, nil), Settings{
+		Volume:   50,
+		Location: paris,
+		Clock:    clock.Now,
+	})
+	c.schedule = make(map[string]pocketbase.Alarm, len(alarms))
+	for _, alarm := range alarms {
+		c.schedule[alarm.ID] = alarm
+	}
+	return c
+}
+
+// runUntil checks the alarms every 15 seconds until the clock reaches end
+func runUntil(c *Checker, clock *testClock, end time.Time) {
+	for ; !clock.now.After(end); clock.now = clock.now.Add(pollInterval) {
+		c.checkMinutes(c.now())
+	}
+}
+
+// firedAt returns when each alarm result was recorded
+func firedAt(c *Checker) []time.Time {
+	var times []time.Time
+	for _, result := range c.Results() {
+		times = append(times, result.FiredAt)
+	}
+	return times
+}
+
+func assertFiredOnceAt(t *testing.T, c *Checker, want time.Time) {
+	t.Helper()
+
+	results := c.Results()
+	if len(results) != 1 {
+		t.Fatalf("alarm fired %d times at %v, want once at %v", len(results), firedAt(c), want)
+	}
+	if !results[0].FiredAt.Equal(want) {
+		t.Errorf("alarm fired at %v, want %v", results[0].FiredAt, want)
+	}
+	if !results[0].Rang {
+		t.Errorf("alarm did not ring: %s", results[0].Error)
+	}
+}
+
+// Europe/Paris goes from 02:00 CET to 03:00 CEST on 2024-03-31 at 01:00 UTC,
+// and from 03:00 CEST back to 02:00 CET on 2024-10-27 at 01:00 UTC
+var (
+	springForward = time.Date(2024, 3, 31, 1, 0, 0, 0, time.UTC)
+	fallBack      = time.Date(2024, 10, 27, 1, 0, 0, 0, time.UTC)
+)
+
+func TestSkippedTimeFiresOnce(t *testing.T) {
+	clock := &testClock{now: springForward.Add(-30 * time.Minute)}
+	c := newTestChecker(t, clock, pocketbase.Alarm{ID: "alarm1", Hour: "02:30", Activated: true, PlaylistID: "playlist1"})
+
+	runUntil(c, clock, springForward.Add(90*time.Minute))
+
+	// 02:30 does not exist that night, the alarm rings at 03:00 CEST
+	assertFiredOnceAt(t, c, springForward)
+}
+
+func TestRepeatedTimeFiresOnce(t *testing.T) {
+	clock := &testClock{now: fallBack.Add(-90 * time.Minute)}
+	c := newTestChecker(t, clock, pocketbase.Alarm{ID: "alarm1", Hour: "02:30", Activated: true, PlaylistID: "playlist1"})
+
+	runUntil(c, clock, fallBack.Add(90*time.Minute))
+
+	// 02:30 CEST rings, 02:30 CET an hour later does not
+	assertFiredOnceAt(t, c, fallBack.Add(-30*time.Minute))
+}
+
+func TestCatchUpAcrossSpringForward(t *testing.T) {
+	clock := &testClock{now: springForward.Add(-5 * time.Minute)}
+	c := newTestChecker(t, clock, pocketbase.Alarm{ID: "alarm1", Hour: "02:30", Activated: true, PlaylistID: "playlist1"})
+
+	// Suspended from 01:55 CET until 03:04 CEST
+	c.checkMinutes(c.now())
+	clock.now = springForward.Add(4 * time.Minute)
+	runUntil(c, clock, springForward.Add(30*time.Minute))
+
+	assertFiredOnceAt(t, c, springForward.Add(4*time.Minute))
+}
+
+func TestCatchUpAcrossFallBack(t *testing.T) {
+	clock := &testClock{now: fallBack.Add(-5 * time.Minute)}
+	c := newTestChecker(t, clock, pocketbase.Alarm{ID: "alarm1", Hour: "02:58", Activated: true, PlaylistID: "playlist1"})
+
+	// Suspended from 02:55 CEST until 02:04 CET, missing 02:58 CEST, which
+	// rings late but not again when 02:58 CET comes
+	c.checkMinutes(c.now())
+	clock.now = fallBack.Add(4 * time.Minute)
+	runUntil(c, clock, fallBack.Add(70*time.Minute))
+
+	assertFiredOnceAt(t, c, fallBack.Add(4*time.Minute))
+}
+
+func TestCatchUpAfterFirstLoad(t *testing.T) {
+	start := time.Date(2024, 6, 3, 5, 0, 20, 0, time.UTC)
+	clock := &testClock{now: start}
+	c := newTestChecker(t, clock, pocketbase.Alarm{ID: "alarm1", Hour: "07:01", Activated: true, PlaylistID: "playlist1"})
+
+	// PocketBase is unreachable at 07:00 CEST when the checker starts, and
+	// the alarms are loaded at 07:03
+	schedule := c.schedule
+	c.schedule = nil
+	runUntil(c, clock, start.Add(3*time.Minute))
+	c.schedule = schedule
+	runUntil(c, clock, start.Add(5*time.Minute))
+
+	assertFiredOnceAt(t, c, start.Add(3*time.Minute+15*time.Second))
+}
+
+func TestNextFireAcrossDST(t *testing.T) {
+	paris, err := time.LoadLocation("Europe/Paris")
+	if err != nil {
+		t.Skipf("Europe/Paris timezone not available: %v", err)
+	}
+	alarm := pocketbase.Alarm{ID: "alarm1", Hour: "02:30", Activated: true}
+
+	tests := []struct {
+		name  string
+		after time.Time
+		want  time.Time
+	}{
+		{"skipped time", springForward.Add(-time.Hour), springForward},
+		{"repeated time", fallBack.Add(-2 * time.Hour), fallBack.Add(-30 * time.Minute)},
+		{"after the repeated time", fallBack, fallBack.Add(24*time.Hour + 30*time.Minute)},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, ok := NextFire(alarm, tt.after, paris)
+			if !ok || !got.Equal(tt.want) {
+				t.Errorf("NextFire(%v) = %v, %v, want %v", tt.after, got, ok, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,141 @@
+package alarms
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"time"
+
+	"cartophone-server/internal/pocketbase"
+	"cartophone-server/internal/utils"
+)
+
+// alarmsTopic is the realtime subscription of the alarms collection
+const alarmsTopic = "alarms"
+
+// ErrNotLoaded is returned while the alarms have not been loaded from PocketBase yet
+var ErrNotLoaded = errors.New("alarms are not loaded yet")
+
+// Delays between two attempts to reach PocketBase after a failure
+const (
+	minSyncRetryDelay = time.Second
+	maxSyncRetryDelay = time.Minute
+)
+
+// syncAlarms keeps the in-memory alarms in sync with PocketBase: it follows
+// the changes of the alarms collection through the realtime API and reloads
+// all the alarms each time it (re)subscribes, so that no change is missed
+// while disconnected.
+func (c *Checker) syncAlarms() {
+	ctx := context.Background()
+	delay := minSyncRetryDelay
+
+	for {
+		connected := false
+		err := c.pocketBase.Subscribe(ctx, []string{alarmsTopic}, func() {
+			connected = true
+			if err := c.loadAlarms(ctx); err != nil {
+				utils.LogMessage("ERROR", "Failed to load alarms, using the ones in memory", err.Error())
+			}
+		}, c.applyAlarmEvent)
+
+		if connected {
+			delay = minSyncRetryDelay
+		}
+		utils.LogMessage("ERROR", "Lost PocketBase realtime connection, alarms may be out of date", map[string]interface{}{
+			"error":   err.Error(),
+			"retryIn": delay.String(),
+		})
+
+		// Without realtime updates, a reload still catches up on changes
+		if !connected {
+			if err := c.loadAlarms(ctx); err != nil {
+				utils.LogMessage("ERROR", "Failed to load alarms, using the ones in memory", err.Error())
+			}
+		}
+
+		time.Sleep(delay)
+		delay *= 2
+		if delay > maxSyncRetryDelay {
+			delay = maxSyncRetryDelay
+		}
+	}
+}
+
+// loadAlarms replaces the in-memory alarms with the ones in PocketBase
+func (c *Checker) loadAlarms(ctx context.Context) error {
+	alarms, err := c.pocketBase.ListAlarms(ctx)
+	if err != nil {
+		return err
+	}
+
+	schedule := make(map[string]pocketbase.Alarm, len(alarms))
+	for _, alarm := range alarms {
+		schedule[alarm.ID] = alarm
+	}
+
+	c.scheduleMu.Lock()
+	c.schedule = schedule
+	c.scheduleMu.Unlock()
+
+	utils.LogMessage("INFO", "Loaded alarms in memory", map[string]interface{}{"count": len(alarms)})
+	return nil
+}
+
+// applyAlarmEvent updates the in-memory alarms with a realtime change
+func (c *Checker) applyAlarmEvent(event pocketbase.RealtimeEvent) {
+	var alarm pocketbase.Alarm
+	if err := json.Unmarshal(event.Record, &alarm); err != nil || alarm.ID == "" {
+		utils.LogMessage("ERROR", "Invalid alarm in realtime event", map[string]interface{}{"action": event.Action})
+		return
+	}
+
+	c.scheduleMu.Lock()
+	defer c.scheduleMu.Unlock()
+
+	// The next load includes the change
+	if c.schedule == nil {
+		return
+	}
+
+	switch event.Action {
+	case pocketbase.ActionCreate, pocketbase.ActionUpdate:
+		c.schedule[alarm.ID] = alarm
+	case pocketbase.ActionDelete:
+		delete(c.schedule, alarm.ID)
+	default:
+		return
+	}
+
+	utils.LogMessage("INFO", "Alarm changed in PocketBase", map[string]interface{}{"action": event.Action, "alarm": alarm})
+}
+
+// activatedAlarms returns the activated alarms in memory. ok is false until
+// the alarms were loaded from PocketBase once.
+func (c *Checker) activatedAlarms() (alarms []pocketbase.Alarm, ok bool) {
+	c.scheduleMu.Lock()
+	defer c.scheduleMu.Unlock()
+
+	if c.schedule == nil {
+		return nil, false
+	}
+
+	for _, alarm := range c.schedule {
+		if alarm.Activated {
+			alarms = append(alarms, alarm)
+		}
+	}
+	return alarms, true
+}
+
+// deactivateInMemory deactivates an alarm in memory, so that it does not
+// ring again even when PocketBase could not be updated
+func (c *Checker) deactivateInMemory(id string) {
+	c.scheduleMu.Lock()
+	defer c.scheduleMu.Unlock()
+
+	if alarm, ok := c.schedule[id]; ok {
+		alarm.Activated = false
+		c.schedule[id] = alarm
+	}
+}
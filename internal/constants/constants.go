@@ -3,4 +3,36 @@ package constants
 const (
     ReadMode      = "read"
     AssociateMode = "associate"
-)
\ No newline at end of file
+)
+
+// Card actions. A card without an action plays its playlist.
+const (
+    ActionPlaylist   = "playlist"
+    ActionPlayPause  = "play_pause"
+    ActionNext       = "next"
+    ActionPrevious   = "previous"
+    ActionVolumeUp   = "volume_up"
+    ActionVolumeDown = "volume_down"
+    ActionShuffle    = "shuffle"
+    ActionStop       = "stop"
+    ActionSnooze     = "snooze_alarm"
+    ActionStopAlarm  = "stop_alarm"
+    ActionSleepTimer = "sleep_timer"
+)
+
+// What scanning any card does while an alarm is ringing
+const (
+    AlarmCardSnooze = "snooze"
+    AlarmCardStop   = "stop"
+    AlarmCardNone   = "none" // The card is handled as usual
+)
+
+// IsCommandAction reports whether action controls the player instead of playing a playlist
+func IsCommandAction(action string) bool {
+    switch action {
+    case ActionPlayPause, ActionNext, ActionPrevious, ActionVolumeUp, ActionVolumeDown, ActionShuffle, ActionStop,
+        ActionSnooze, ActionStopAlarm, ActionSleepTimer:
+        return true
+    }
+    return false
+}
\ No newline at end of file
@@ -2,14 +2,17 @@ package handlers
 
 import (
 	"encoding/json"
+	"errors"
 	"net/http"
+	"time"
 
+	"cartophone-server/internal/alarms"
 	"cartophone-server/internal/pocketbase"
 	"cartophone-server/internal/utils"
 )
 
 // CreateAlarmHandler handles the creation of a new alarm
-func CreateAlarmHandler(baseURL string, w http.ResponseWriter, r *http.Request) {
+func CreateAlarmHandler(pocketBase *pocketbase.Client, w http.ResponseWriter, r *http.Request) {
 	if r.Method != http.MethodPost {
 		utils.WriteJSONResponse(w, http.StatusMethodNotAllowed, map[string]string{"error": "Invalid request method"})
 		utils.LogMessage("ERROR", "Invalid request method for CreateAlarmHandler", nil)
@@ -20,6 +23,13 @@ func CreateAlarmHandler(baseURL string, w http.ResponseWriter, r *http.Request)
 		PlaylistID string `json:"playlistId"`
 		Hour       string `json:"hour"`
 		Activated  bool   `json:"activated"`
+		Weekdays   int    `json:"weekdays"`
+		OneShot    bool   `json:"oneShot"`
+		Date       string `json:"date"`
+		Timezone   string `json:"timezone"`
+
+		Volume        int `json:"volume"`
+		FadeInSeconds int `json:"fadeInSeconds"`
 	}
 
 	if err := json.NewDecoder(r.Body).Decode(&payload); err != nil {
@@ -28,7 +38,36 @@ func CreateAlarmHandler(baseURL string, w http.ResponseWriter, r *http.Request)
 		return
 	}
 
-	alarm, err := pocketbase.CreateAlarm(baseURL, payload.PlaylistID, payload.Hour, payload.Activated)
+	if payload.Volume < 0 || payload.Volume > 100 {
+		utils.WriteJSONResponse(w, http.StatusBadRequest, map[string]string{"error": "Volume must be between 0 and 100"})
+		utils.LogMessage("ERROR", "Invalid alarm volume for CreateAlarmHandler", payload)
+		return
+	}
+
+	if message := validateAlarmHour(payload.Hour); message != "" {
+		utils.WriteJSONResponse(w, http.StatusBadRequest, map[string]string{"error": message})
+		utils.LogMessage("ERROR", "Invalid alarm hour for CreateAlarmHandler", payload)
+		return
+	}
+
+	if message := validateAlarmSchedule(payload.Weekdays, payload.Date, payload.Timezone); message != "" {
+		utils.WriteJSONResponse(w, http.StatusBadRequest, map[string]string{"error": message})
+		utils.LogMessage("ERROR", "Invalid alarm schedule for CreateAlarmHandler", payload)
+		return
+	}
+
+	alarm, err := pocketBase.CreateAlarm(r.Context(), pocketbase.Alarm{
+		PlaylistID: payload.PlaylistID,
+		Hour:       payload.Hour,
+		Activated:  payload.Activated,
+		Weekdays:   payload.Weekdays,
+		OneShot:    payload.OneShot,
+		Date:       payload.Date,
+		Timezone:   payload.Timezone,
+
+		Volume:        payload.Volume,
+		FadeInSeconds: payload.FadeInSeconds,
+	})
 	if err != nil {
 		utils.WriteJSONResponse(w, http.StatusInternalServerError, map[string]string{"error": "Failed to create alarm"})
 		utils.LogMessage("ERROR", "Failed to create alarm in PocketBase", err.Error())
@@ -40,7 +79,7 @@ func CreateAlarmHandler(baseURL string, w http.ResponseWriter, r *http.Request)
 }
 
 // DeleteAlarmHandler handles the deletion of an alarm by ID
-func DeleteAlarmHandler(baseURL string, w http.ResponseWriter, r *http.Request) {
+func DeleteAlarmHandler(pocketBase *pocketbase.Client, w http.ResponseWriter, r *http.Request) {
 	if r.Method != http.MethodDelete {
 		utils.WriteJSONResponse(w, http.StatusMethodNotAllowed, map[string]string{"error": "Invalid request method"})
 		utils.LogMessage("ERROR", "Invalid request method for DeleteAlarmHandler", nil)
@@ -57,7 +96,7 @@ func DeleteAlarmHandler(baseURL string, w http.ResponseWriter, r *http.Request)
 		return
 	}
 
-	err := pocketbase.DeleteAlarm(baseURL, payload.ID)
+	err := pocketBase.DeleteAlarm(r.Context(), payload.ID)
 	if err != nil {
 		utils.WriteJSONResponse(w, http.StatusInternalServerError, map[string]string{"error": "Failed to delete alarm"})
 		utils.LogMessage("ERROR", "Failed to delete alarm in PocketBase", err.Error())
@@ -68,21 +107,59 @@ func DeleteAlarmHandler(baseURL string, w http.ResponseWriter, r *http.Request)
 	utils.LogMessage("INFO", "Alarm deleted successfully", payload.ID)
 }
 
+// listedAlarm is an alarm with the next time it rings
+type listedAlarm struct {
+	pocketbase.Alarm
+	NextFireAt *time.Time `json:"nextFireAt"` // Null when the alarm will not ring
+}
+
 // ListAlarmsHandler handles listing all alarms
-func ListAlarmsHandler(baseURL string, w http.ResponseWriter, r *http.Request) {
-	alarms, err := pocketbase.ListAlarms(baseURL)
+func ListAlarmsHandler(pocketBase *pocketbase.Client, checker *alarms.Checker, w http.ResponseWriter, r *http.Request) {
+	list, err := pocketBase.ListAlarms(r.Context())
 	if err != nil {
 		utils.WriteJSONResponse(w, http.StatusInternalServerError, map[string]string{"error": "Failed to list alarms"})
 		utils.LogMessage("ERROR", "Failed to list alarms from PocketBase", err.Error())
 		return
 	}
 
-	utils.WriteJSONResponse(w, http.StatusOK, alarms)
-	utils.LogMessage("INFO", "Listed all alarms successfully", alarms)
+	listed := make([]listedAlarm, len(list))
+	for i, alarm := range list {
+		listed[i] = listedAlarm{Alarm: alarm}
+		if next, ok := checker.NextFireAt(alarm); ok {
+			listed[i].NextFireAt = &next
+		}
+	}
+
+	utils.WriteJSONResponse(w, http.StatusOK, listed)
+	utils.LogMessage("INFO", "Listed all alarms successfully", listed)
+}
+
+// NextAlarmHandler returns the alarm that rings next and when
+func NextAlarmHandler(checker *alarms.Checker, w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		utils.WriteJSONResponse(w, http.StatusMethodNotAllowed, map[string]string{"error": "Invalid request method"})
+		utils.LogMessage("ERROR", "Invalid request method for NextAlarmHandler", nil)
+		return
+	}
+
+	next, err := checker.Next()
+	if errors.Is(err, alarms.ErrNotLoaded) {
+		utils.WriteJSONResponse(w, http.StatusServiceUnavailable, map[string]string{"error": "Alarms are not loaded yet"})
+		utils.LogMessage("INFO", "Next alarm requested before the alarms were loaded", nil)
+		return
+	}
+	if next == nil {
+		utils.WriteJSONResponse(w, http.StatusNotFound, map[string]string{"error": "No upcoming alarm"})
+		utils.LogMessage("INFO", "No upcoming alarm", nil)
+		return
+	}
+
+	utils.WriteJSONResponse(w, http.StatusOK, next)
+	utils.LogMessage("INFO", "Fetched next alarm successfully", next)
 }
 
 // SetAlarmStatusHandler handles updating the activation status of an alarm
-func SetAlarmStatusHandler(baseURL string, w http.ResponseWriter, r *http.Request) {
+func SetAlarmStatusHandler(pocketBase *pocketbase.Client, w http.ResponseWriter, r *http.Request) {
 	if r.Method != http.MethodPatch {
 		utils.WriteJSONResponse(w, http.StatusMethodNotAllowed, map[string]string{"error": "Invalid request method"})
 		utils.LogMessage("ERROR", "Invalid request method for SetAlarmStatusHandler", nil)
@@ -100,7 +177,7 @@ func SetAlarmStatusHandler(baseURL string, w http.ResponseWriter, r *http.Reques
 		return
 	}
 
-	err := pocketbase.SetAlarmStatus(baseURL, payload.ID, payload.Activated)
+	err := pocketBase.SetAlarmStatus(r.Context(), payload.ID, payload.Activated)
 	if err != nil {
 		utils.WriteJSONResponse(w, http.StatusInternalServerError, map[string]string{"error": "Failed to update alarm status"})
 		utils.LogMessage("ERROR", "Failed to update alarm status in PocketBase", err.Error())
@@ -112,7 +189,7 @@ func SetAlarmStatusHandler(baseURL string, w http.ResponseWriter, r *http.Reques
 }
 
 // ChangeAlarmPlaylistHandler handles changing the playlist of an alarm
-func ChangeAlarmPlaylistHandler(baseURL string, w http.ResponseWriter, r *http.Request) {
+func ChangeAlarmPlaylistHandler(pocketBase *pocketbase.Client, w http.ResponseWriter, r *http.Request) {
 	if r.Method != http.MethodPatch {
 		utils.WriteJSONResponse(w, http.StatusMethodNotAllowed, map[string]string{"error": "Invalid request method"})
 		utils.LogMessage("ERROR", "Invalid request method for ChangeAlarmPlaylistHandler", nil)
@@ -130,7 +207,7 @@ func ChangeAlarmPlaylistHandler(baseURL string, w http.ResponseWriter, r *http.R
 		return
 	}
 
-	err := pocketbase.ChangeAlarmPlaylist(baseURL, payload.ID, payload.PlaylistID)
+	err := pocketBase.ChangeAlarmPlaylist(r.Context(), payload.ID, payload.PlaylistID)
 	if err != nil {
 		utils.WriteJSONResponse(w, http.StatusInternalServerError, map[string]string{"error": "Failed to change alarm playlist"})
 		utils.LogMessage("ERROR", "Failed to change alarm playlist in PocketBase", err.Error())
@@ -142,7 +219,7 @@ func ChangeAlarmPlaylistHandler(baseURL string, w http.ResponseWriter, r *http.R
 }
 
 // ChangeAlarmHourHandler handles changing the hour of an alarm
-func ChangeAlarmHourHandler(baseURL string, w http.ResponseWriter, r *http.Request) {
+func ChangeAlarmHourHandler(pocketBase *pocketbase.Client, w http.ResponseWriter, r *http.Request) {
 	if r.Method != http.MethodPatch {
 		utils.WriteJSONResponse(w, http.StatusMethodNotAllowed, map[string]string{"error": "Invalid request method"})
 		utils.LogMessage("ERROR", "Invalid request method for ChangeAlarmHourHandler", nil)
@@ -160,7 +237,13 @@ func ChangeAlarmHourHandler(baseURL string, w http.ResponseWriter, r *http.Reque
 		return
 	}
 
-	err := pocketbase.ChangeAlarmHour(baseURL, payload.ID, payload.Hour)
+	if message := validateAlarmHour(payload.Hour); message != "" {
+		utils.WriteJSONResponse(w, http.StatusBadRequest, map[string]string{"error": message})
+		utils.LogMessage("ERROR", "Invalid alarm hour for ChangeAlarmHourHandler", payload)
+		return
+	}
+
+	err := pocketBase.ChangeAlarmHour(r.Context(), payload.ID, payload.Hour)
 	if err != nil {
 		utils.WriteJSONResponse(w, http.StatusInternalServerError, map[string]string{"error": "Failed to change alarm hour"})
 		utils.LogMessage("ERROR", "Failed to change alarm hour in PocketBase", err.Error())
@@ -169,4 +252,166 @@ func ChangeAlarmHourHandler(baseURL string, w http.ResponseWriter, r *http.Reque
 
 	utils.WriteJSONResponse(w, http.StatusOK, map[string]string{"message": "Alarm hour updated successfully"})
 	utils.LogMessage("INFO", "Alarm hour updated successfully", payload)
-}
\ No newline at end of file
+}
+
+// ChangeAlarmScheduleHandler handles changing the weekdays, one-shot flag, date and timezone of an alarm
+func ChangeAlarmScheduleHandler(pocketBase *pocketbase.Client, w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPatch {
+		utils.WriteJSONResponse(w, http.StatusMethodNotAllowed, map[string]string{"error": "Invalid request method"})
+		utils.LogMessage("ERROR", "Invalid request method for ChangeAlarmScheduleHandler", nil)
+		return
+	}
+
+	var payload struct {
+		ID       string `json:"id"`
+		Weekdays int    `json:"weekdays"`
+		OneShot  bool   `json:"oneShot"`
+		Date     string `json:"date"`
+		Timezone string `json:"timezone"`
+	}
+
+	if err := json.NewDecoder(r.Body).Decode(&payload); err != nil {
+		utils.WriteJSONResponse(w, http.StatusBadRequest, map[string]string{"error": "Invalid request body"})
+		utils.LogMessage("ERROR", "Failed to decode request body for ChangeAlarmScheduleHandler", err.Error())
+		return
+	}
+
+	if message := validateAlarmSchedule(payload.Weekdays, payload.Date, payload.Timezone); message != "" {
+		utils.WriteJSONResponse(w, http.StatusBadRequest, map[string]string{"error": message})
+		utils.LogMessage("ERROR", "Invalid alarm schedule for ChangeAlarmScheduleHandler", payload)
+		return
+	}
+
+	err := pocketBase.ChangeAlarmSchedule(r.Context(), payload.ID, payload.Weekdays, payload.OneShot, payload.Date, payload.Timezone)
+	if err != nil {
+		utils.WriteJSONResponse(w, http.StatusInternalServerError, map[string]string{"error": "Failed to change alarm schedule"})
+		utils.LogMessage("ERROR", "Failed to change alarm schedule in PocketBase", err.Error())
+		return
+	}
+
+	utils.WriteJSONResponse(w, http.StatusOK, map[string]string{"message": "Alarm schedule updated successfully"})
+	utils.LogMessage("INFO", "Alarm schedule updated successfully", payload)
+}
+
+// ChangeAlarmVolumeHandler handles updating the volume and fade-in duration of an alarm
+func ChangeAlarmVolumeHandler(pocketBase *pocketbase.Client, w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPatch {
+		utils.WriteJSONResponse(w, http.StatusMethodNotAllowed, map[string]string{"error": "Invalid request method"})
+		utils.LogMessage("ERROR", "Invalid request method for ChangeAlarmVolumeHandler", nil)
+		return
+	}
+
+	var payload struct {
+		ID            string `json:"id"`
+		Volume        int    `json:"volume"`
+		FadeInSeconds int    `json:"fadeInSeconds"`
+	}
+
+	if err := json.NewDecoder(r.Body).Decode(&payload); err != nil {
+		utils.WriteJSONResponse(w, http.StatusBadRequest, map[string]string{"error": "Invalid request body"})
+		utils.LogMessage("ERROR", "Failed to decode request body for ChangeAlarmVolumeHandler", err.Error())
+		return
+	}
+
+	if payload.Volume < 0 || payload.Volume > 100 {
+		utils.WriteJSONResponse(w, http.StatusBadRequest, map[string]string{"error": "Volume must be between 0 and 100"})
+		utils.LogMessage("ERROR", "Invalid alarm volume for ChangeAlarmVolumeHandler", payload)
+		return
+	}
+
+	err := pocketBase.ChangeAlarmVolume(r.Context(), payload.ID, payload.Volume, payload.FadeInSeconds)
+	if err != nil {
+		utils.WriteJSONResponse(w, http.StatusInternalServerError, map[string]string{"error": "Failed to change alarm volume"})
+		utils.LogMessage("ERROR", "Failed to change alarm volume in PocketBase", err.Error())
+		return
+	}
+
+	utils.WriteJSONResponse(w, http.StatusOK, map[string]string{"message": "Alarm volume updated successfully"})
+	utils.LogMessage("INFO", "Alarm volume updated successfully", payload)
+}
+
+// validateAlarmHour checks that an alarm hour uses the HH:MM format, returning an error message when invalid
+func validateAlarmHour(hour string) string {
+	parsed, err := time.Parse("15:04", hour)
+	if err != nil || parsed.Format("15:04") != hour {
+		return "Hour must use the HH:MM format, such as 07:30"
+	}
+	return ""
+}
+
+// validateAlarmSchedule checks the weekday mask, date and timezone of an alarm, returning an error message when invalid
+func validateAlarmSchedule(weekdays int, date, timezone string) string {
+	if weekdays < 0 || weekdays > pocketbase.AllDays {
+		return "Weekdays must be a mask between 0 and 127"
+	}
+	if date != "" {
+		if _, err := time.Parse(pocketbase.DateLayout, date); err != nil {
+			return "Date must use the YYYY-MM-DD format"
+		}
+	}
+	if timezone != "" {
+		if _, err := time.LoadLocation(timezone); err != nil {
+			return "Timezone must be an IANA timezone such as Europe/Paris"
+		}
+	}
+	return ""
+}
+
+// AlarmResultsHandler lists the most recent alarm firings and whether they rang
+func AlarmResultsHandler(checker *alarms.Checker, w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		utils.WriteJSONResponse(w, http.StatusMethodNotAllowed, map[string]string{"error": "Invalid request method"})
+		utils.LogMessage("ERROR", "Invalid request method for AlarmResultsHandler", nil)
+		return
+	}
+
+	results := checker.Results()
+	utils.WriteJSONResponse(w, http.StatusOK, results)
+	utils.LogMessage("INFO", "Listed alarm results successfully", map[string]interface{}{"count": len(results)})
+}
+
+// SnoozeAlarmHandler snoozes the ringing alarm
+func SnoozeAlarmHandler(checker *alarms.Checker, w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		utils.WriteJSONResponse(w, http.StatusMethodNotAllowed, map[string]string{"error": "Invalid request method"})
+		utils.LogMessage("ERROR", "Invalid request method for SnoozeAlarmHandler", nil)
+		return
+	}
+
+	ringing, err := checker.Snooze(r.Context())
+	if errors.Is(err, alarms.ErrNotRinging) {
+		utils.WriteJSONResponse(w, http.StatusConflict, map[string]string{"error": "No alarm is ringing"})
+		utils.LogMessage("INFO", "Snooze requested while no alarm is ringing", nil)
+		return
+	} else if err != nil {
+		utils.WriteJSONResponse(w, http.StatusInternalServerError, map[string]string{"error": "Failed to snooze alarm"})
+		utils.LogMessage("ERROR", "Failed to snooze alarm", err.Error())
+		return
+	}
+
+	utils.WriteJSONResponse(w, http.StatusOK, ringing)
+	utils.LogMessage("INFO", "Alarm snoozed successfully", ringing)
+}
+
+// StopAlarmHandler stops the ringing alarm
+func StopAlarmHandler(checker *alarms.Checker, w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		utils.WriteJSONResponse(w, http.StatusMethodNotAllowed, map[string]string{"error": "Invalid request method"})
+		utils.LogMessage("ERROR", "Invalid request method for StopAlarmHandler", nil)
+		return
+	}
+
+	ringing, err := checker.Stop(r.Context())
+	if errors.Is(err, alarms.ErrNotRinging) {
+		utils.WriteJSONResponse(w, http.StatusConflict, map[string]string{"error": "No alarm is ringing"})
+		utils.LogMessage("INFO", "Stop requested while no alarm is ringing", nil)
+		return
+	} else if err != nil {
+		utils.WriteJSONResponse(w, http.StatusInternalServerError, map[string]string{"error": "Failed to stop alarm"})
+		utils.LogMessage("ERROR", "Failed to stop alarm", err.Error())
+		return
+	}
+
+	utils.WriteJSONResponse(w, http.StatusOK, map[string]interface{}{"message": "Alarm stopped", "alarm": ringing})
+	utils.LogMessage("INFO", "Alarm stopped successfully", ringing)
+}
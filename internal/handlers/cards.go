@@ -3,19 +3,22 @@ package handlers
 import (
 	"encoding/json"
 	"net/http"
+	"strings"
 	"time"
 
 	"cartophone-server/internal/constants"
+	"cartophone-server/internal/nfc"
 	"cartophone-server/internal/pocketbase"
 	"cartophone-server/internal/utils"
 )
 
-func AssociateCardHandler(cardDetectedChan <-chan string, modeSwitch chan string, baseURL string, w http.ResponseWriter, r *http.Request) {
+func AssociateCardHandler(cardDetectedChan <-chan nfc.Event, modeSwitch chan string, pocketBase *pocketbase.Client, w http.ResponseWriter, r *http.Request) {
 	utils.LogMessage("DEBUG", "AssociateHandler started. Processing request...", nil)
 
-	// Parse playlist ID and replaceCard flag
+	// Parse playlist ID or card action and replaceCard flag
 	var payload struct {
 		PlaylistID  string `json:"playlistId"`
+		Action      string `json:"action,omitempty"`
 		ReplaceCard bool   `json:"replaceCard,omitempty"`
 	}
 	if err := json.NewDecoder(r.Body).Decode(&payload); err != nil {
@@ -24,14 +27,27 @@ func AssociateCardHandler(cardDetectedChan <-chan string, modeSwitch chan string
 		return
 	}
 
-	if payload.PlaylistID == "" {
-		utils.WriteJSONResponse(w, http.StatusBadRequest, map[string]string{"error": "Playlist ID is required"})
-		utils.LogMessage("ERROR", "Playlist ID is missing in the request payload", nil)
+	if payload.Action == "" {
+		payload.Action = constants.ActionPlaylist
+	}
+
+	if payload.Action == constants.ActionPlaylist {
+		if payload.PlaylistID == "" {
+			utils.WriteJSONResponse(w, http.StatusBadRequest, map[string]string{"error": "Playlist ID is required"})
+			utils.LogMessage("ERROR", "Playlist ID is missing in the request payload", nil)
+			return
+		}
+	} else if constants.IsCommandAction(payload.Action) {
+		payload.PlaylistID = "" // Command cards do not play a playlist
+	} else {
+		utils.WriteJSONResponse(w, http.StatusBadRequest, map[string]string{"error": "Unknown card action"})
+		utils.LogMessage("ERROR", "Unknown card action in the request payload", payload.Action)
 		return
 	}
 
 	utils.LogMessage("DEBUG", "Associate mode requested", map[string]interface{}{
 		"playlistId":  payload.PlaylistID,
+		"action":      payload.Action,
 		"replaceCard": payload.ReplaceCard,
 	})
 
@@ -44,27 +60,26 @@ func AssociateCardHandler(cardDetectedChan <-chan string, modeSwitch chan string
 	}
 
 	// Listen for a card or timeout
-	select {
-	case uid := <-cardDetectedChan:
+	if uid, detected := waitForPlacedCard(cardDetectedChan, 10*time.Second); detected {
 		utils.LogMessage("DEBUG", "Detected card UID in associate mode", uid)
 
 		// Check if the card exists in PocketBase
-		card, err := pocketbase.CheckCard(baseURL, uid)
+		card, err := pocketBase.CheckCard(r.Context(), uid)
 		if err != nil {
 			utils.WriteJSONResponse(w, http.StatusInternalServerError, map[string]string{"error": "Error checking card in PocketBase"})
 			utils.LogMessage("ERROR", "Error checking card in PocketBase", err.Error())
 			return
 		}
 
-		if card != nil && card.PlaylistID != "" {
-			if card.PlaylistID == payload.PlaylistID {
+		if card != nil && isCardAssigned(card) {
+			if cardAction(card) == payload.Action && card.PlaylistID == payload.PlaylistID {
 				utils.WriteJSONResponse(w, http.StatusConflict, map[string]string{
-					"message": "Card is already associated with this playlist",
+					"message": "Card is already associated with this playlist or action",
 				})
-				utils.LogMessage("INFO", "Card is already associated with the requested playlist", card)
+				utils.LogMessage("INFO", "Card is already associated with the requested playlist or action", card)
 			} else if payload.ReplaceCard {
-				card.PlaylistID = payload.PlaylistID
-				err = pocketbase.UpdateCard(baseURL, *card)
+				assignCard(card, payload.PlaylistID, payload.Action)
+				err = pocketBase.UpdateCard(r.Context(), card.ID, assignmentFields(card))
 				if err != nil {
 					utils.WriteJSONResponse(w, http.StatusInternalServerError, map[string]string{"error": "Error updating card in PocketBase"})
 					utils.LogMessage("ERROR", "Error updating card in PocketBase", err.Error())
@@ -73,29 +88,51 @@ func AssociateCardHandler(cardDetectedChan <-chan string, modeSwitch chan string
 				}
 
 				utils.WriteJSONResponse(w, http.StatusOK, map[string]string{
-					"message":    "Card reassigned to the new playlist",
+					"message":    "Card reassigned",
 					"cardId":     card.ID,
 					"playlistId": card.PlaylistID,
+					"action":     card.Action,
 				})
-				utils.LogMessage("INFO", "Card reassigned to the new playlist", card)
+				utils.LogMessage("INFO", "Card reassigned", card)
 			} else {
 				utils.WriteJSONResponse(w, http.StatusConflict, map[string]interface{}{
-					"message":    "Card is already associated with another playlist",
+					"message":    "Card is already associated with another playlist or action",
 					"cardId":     card.ID,
 					"playlistId": card.PlaylistID,
+					"action":     cardAction(card),
 				})
-				utils.LogMessage("INFO", "Card is associated with another playlist", card)
+				utils.LogMessage("INFO", "Card is associated with another playlist or action", card)
 			}
 			switchToReadMode(modeSwitch)
 			return
 		}
 
-		// Add a new card
-		newCard := pocketbase.Card{
-			UID:        uid,
-			PlaylistID: payload.PlaylistID,
+		// Assign a registered card that has no playlist or action yet
+		if card != nil {
+			assignCard(card, payload.PlaylistID, payload.Action)
+			err = pocketBase.UpdateCard(r.Context(), card.ID, assignmentFields(card))
+			if err != nil {
+				utils.WriteJSONResponse(w, http.StatusInternalServerError, map[string]string{"error": "Error updating card in PocketBase"})
+				utils.LogMessage("ERROR", "Error updating card in PocketBase", err.Error())
+				switchToReadMode(modeSwitch)
+				return
+			}
+
+			utils.WriteJSONResponse(w, http.StatusOK, map[string]string{
+				"message":    "Card associated successfully",
+				"cardId":     card.ID,
+				"playlistId": card.PlaylistID,
+				"action":     card.Action,
+			})
+			utils.LogMessage("INFO", "Card associated successfully", card)
+			switchToReadMode(modeSwitch)
+			return
 		}
-		err = pocketbase.AddCard(baseURL, newCard)
+
+		// Add a new card
+		newCard := pocketbase.Card{UID: uid}
+		assignCard(&newCard, payload.PlaylistID, payload.Action)
+		err = pocketBase.AddCard(r.Context(), newCard)
 		if err != nil {
 			utils.WriteJSONResponse(w, http.StatusInternalServerError, map[string]string{"error": "Error adding card to PocketBase"})
 			utils.LogMessage("ERROR", "Error adding card to PocketBase", err.Error())
@@ -107,10 +144,10 @@ func AssociateCardHandler(cardDetectedChan <-chan string, modeSwitch chan string
 			"message":    "Card associated successfully",
 			"cardId":     newCard.ID,
 			"playlistId": newCard.PlaylistID,
+			"action":     newCard.Action,
 		})
 		utils.LogMessage("INFO", "Card associated successfully", newCard)
-
-	case <-time.After(10 * time.Second):
+	} else {
 		utils.WriteJSONResponse(w, http.StatusRequestTimeout, map[string]string{"error": "No card detected within 10 seconds"})
 		utils.LogMessage("DEBUG", "No card detected within timeout period", nil)
 	}
@@ -119,6 +156,52 @@ func AssociateCardHandler(cardDetectedChan <-chan string, modeSwitch chan string
 	switchToReadMode(modeSwitch)
 }
 
+// cardAction returns the action of a card, cards without one play their playlist
+func cardAction(card *pocketbase.Card) string {
+	if card.Action == "" {
+		return constants.ActionPlaylist
+	}
+	return card.Action
+}
+
+// isCardAssigned reports whether a card already plays a playlist or runs a command
+func isCardAssigned(card *pocketbase.Card) bool {
+	return card.PlaylistID != "" || constants.IsCommandAction(card.Action)
+}
+
+// assignCard points a card to a playlist or command, starting the playlist from the beginning
+func assignCard(card *pocketbase.Card, playlistID, action string) {
+	card.PlaylistID = playlistID
+	card.Action = action
+	card.QueuePosition = 0
+	card.ProgressMs = 0
+}
+
+// assignmentFields returns the fields of a card changed by assignCard
+func assignmentFields(card *pocketbase.Card) map[string]interface{} {
+	return map[string]interface{}{
+		"playlistId":    card.PlaylistID,
+		"action":        card.Action,
+		"queuePosition": card.QueuePosition,
+		"progressMs":    card.ProgressMs,
+	}
+}
+
+// waitForPlacedCard waits for a card to be placed on the reader, ignoring removals
+func waitForPlacedCard(cardDetectedChan <-chan nfc.Event, timeout time.Duration) (string, bool) {
+	deadline := time.After(timeout)
+	for {
+		select {
+		case event := <-cardDetectedChan:
+			if event.Type == nfc.CardPlaced {
+				return event.UID, true
+			}
+		case <-deadline:
+			return "", false
+		}
+	}
+}
+
 func switchToReadMode(modeSwitch chan string) {
 	select {
 	case modeSwitch <- constants.ReadMode:
@@ -126,4 +209,183 @@ func switchToReadMode(modeSwitch chan string) {
 	default:
 		utils.LogMessage("DEBUG", "ReadMode signal already sent", nil)
 	}
+}
+
+// listedCard is a card with the name of the playlist it plays
+type listedCard struct {
+	pocketbase.Card
+	PlaylistName string `json:"playlistName,omitempty"`
+}
+
+// ListCardsHandler lists all cards along with the name of their playlist
+func ListCardsHandler(pocketBase *pocketbase.Client, w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		utils.WriteJSONResponse(w, http.StatusMethodNotAllowed, map[string]string{"error": "Invalid request method"})
+		utils.LogMessage("ERROR", "Invalid request method for ListCardsHandler", nil)
+		return
+	}
+
+	cards, err := pocketBase.ListCards(r.Context())
+	if err != nil {
+		utils.WriteJSONResponse(w, http.StatusInternalServerError, map[string]string{"error": "Failed to list cards"})
+		utils.LogMessage("ERROR", "Failed to list cards from PocketBase", err.Error())
+		return
+	}
+	playlists, err := pocketBase.ListPlaylists(r.Context())
+	if err != nil {
+		utils.WriteJSONResponse(w, http.StatusInternalServerError, map[string]string{"error": "Failed to list playlists"})
+		utils.LogMessage("ERROR", "Failed to list playlists from PocketBase", err.Error())
+		return
+	}
+
+	names := make(map[string]string, len(playlists))
+	for _, playlist := range playlists {
+		names[playlist.ID] = playlist.Name
+	}
+	listed := make([]listedCard, len(cards))
+	for i, card := range cards {
+		listed[i] = listedCard{Card: card, PlaylistName: names[card.PlaylistID]}
+	}
+
+	utils.WriteJSONResponse(w, http.StatusOK, listed)
+	utils.LogMessage("INFO", "Listed all cards successfully", map[string]interface{}{"count": len(listed)})
+}
+
+// GetCardHandler returns the card given by the id query parameter
+func GetCardHandler(pocketBase *pocketbase.Client, w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		utils.WriteJSONResponse(w, http.StatusMethodNotAllowed, map[string]string{"error": "Invalid request method"})
+		utils.LogMessage("ERROR", "Invalid request method for GetCardHandler", nil)
+		return
+	}
+
+	id := r.URL.Query().Get("id")
+	if id == "" {
+		utils.WriteJSONResponse(w, http.StatusBadRequest, map[string]string{"error": "Card ID is required"})
+		return
+	}
+
+	card, err := pocketBase.GetCard(r.Context(), id)
+	if err != nil {
+		utils.WriteJSONResponse(w, http.StatusInternalServerError, map[string]string{"error": "Failed to fetch card"})
+		utils.LogMessage("ERROR", "Failed to fetch card from PocketBase", err.Error())
+		return
+	}
+	if card == nil {
+		utils.WriteJSONResponse(w, http.StatusNotFound, map[string]string{"error": "Card not found"})
+		return
+	}
+
+	utils.WriteJSONResponse(w, http.StatusOK, card)
+}
+
+// UpdateCardHandler renames a card, or changes its playlist, action or
+// outputs. Only the fields present in the request are changed, and an empty
+// playlist ID unassigns the card.
+func UpdateCardHandler(pocketBase *pocketbase.Client, w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPatch {
+		utils.WriteJSONResponse(w, http.StatusMethodNotAllowed, map[string]string{"error": "Invalid request method"})
+		utils.LogMessage("ERROR", "Invalid request method for UpdateCardHandler", nil)
+		return
+	}
+
+	var payload struct {
+		ID         string    `json:"id"`
+		Label      *string   `json:"label"`
+		PlaylistID *string   `json:"playlistId"`
+		Action     *string   `json:"action"`
+		Outputs    *[]string `json:"outputs"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&payload); err != nil || payload.ID == "" {
+		utils.WriteJSONResponse(w, http.StatusBadRequest, map[string]string{"error": "Card ID is required"})
+		utils.LogMessage("ERROR", "Invalid request body for UpdateCardHandler", nil)
+		return
+	}
+
+	card, err := pocketBase.GetCard(r.Context(), payload.ID)
+	if err != nil {
+		utils.WriteJSONResponse(w, http.StatusInternalServerError, map[string]string{"error": "Failed to fetch card"})
+		utils.LogMessage("ERROR", "Failed to fetch card from PocketBase", err.Error())
+		return
+	}
+	if card == nil {
+		utils.WriteJSONResponse(w, http.StatusNotFound, map[string]string{"error": "Card not found"})
+		return
+	}
+
+	action, playlistID := cardAction(card), card.PlaylistID
+	if payload.Action != nil {
+		action = *payload.Action
+		if action == "" {
+			action = constants.ActionPlaylist
+		}
+	}
+	if payload.PlaylistID != nil {
+		playlistID = *payload.PlaylistID
+		if playlistID != "" && payload.Action == nil {
+			action = constants.ActionPlaylist // Giving a command card a playlist makes it play it
+		}
+	}
+	if constants.IsCommandAction(action) {
+		playlistID = "" // Command cards do not play a playlist
+	} else if action != constants.ActionPlaylist {
+		utils.WriteJSONResponse(w, http.StatusBadRequest, map[string]string{"error": "Unknown card action"})
+		utils.LogMessage("ERROR", "Unknown card action in the request payload", action)
+		return
+	}
+
+	// Only send the changed fields, so that the saved position is not
+	// overwritten with the one read above
+	fields := map[string]interface{}{}
+	if action != cardAction(card) || playlistID != card.PlaylistID {
+		assignCard(card, playlistID, action)
+		fields = assignmentFields(card)
+	}
+	if payload.Label != nil {
+		card.Label = strings.TrimSpace(*payload.Label)
+		fields["label"] = card.Label
+	}
+	if payload.Outputs != nil {
+		card.Outputs = *payload.Outputs
+		if card.Outputs == nil {
+			card.Outputs = []string{}
+		}
+		fields["outputs"] = card.Outputs
+	}
+
+	if err := pocketBase.UpdateCard(r.Context(), card.ID, fields); err != nil {
+		utils.WriteJSONResponse(w, http.StatusInternalServerError, map[string]string{"error": "Error updating card in PocketBase"})
+		utils.LogMessage("ERROR", "Error updating card in PocketBase", err.Error())
+		return
+	}
+
+	utils.WriteJSONResponse(w, http.StatusOK, card)
+	utils.LogMessage("INFO", "Card updated successfully", card)
+}
+
+// DeleteCardHandler deletes a card, which is then unknown until associated again
+func DeleteCardHandler(pocketBase *pocketbase.Client, w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodDelete {
+		utils.WriteJSONResponse(w, http.StatusMethodNotAllowed, map[string]string{"error": "Invalid request method"})
+		utils.LogMessage("ERROR", "Invalid request method for DeleteCardHandler", nil)
+		return
+	}
+
+	var payload struct {
+		ID string `json:"id"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&payload); err != nil || payload.ID == "" {
+		utils.WriteJSONResponse(w, http.StatusBadRequest, map[string]string{"error": "Card ID is required"})
+		utils.LogMessage("ERROR", "Invalid request body for DeleteCardHandler", nil)
+		return
+	}
+
+	if err := pocketBase.DeleteCard(r.Context(), payload.ID); err != nil {
+		utils.WriteJSONResponse(w, http.StatusInternalServerError, map[string]string{"error": "Failed to delete card"})
+		utils.LogMessage("ERROR", "Failed to delete card in PocketBase", err.Error())
+		return
+	}
+
+	utils.WriteJSONResponse(w, http.StatusOK, map[string]string{"message": "Card deleted successfully"})
+	utils.LogMessage("INFO", "Card deleted successfully", payload)
 }
\ No newline at end of file
@@ -0,0 +1,48 @@
+package handlers
+
+import (
+	"context"
+	"fmt"
+
+	"cartophone-server/internal/alarms"
+	"cartophone-server/internal/constants"
+	"cartophone-server/internal/owntone"
+	"cartophone-server/internal/sleeptimer"
+)
+
+// volumeStep is the volume change applied by the volume up and down cards
+const volumeStep = 10
+
+// runCardCommand performs the player action of a command card
+func runCardCommand(ctx context.Context, action string, ownTone *owntone.Client, alarmChecker *alarms.Checker, sleepTimer *sleeptimer.Timer) error {
+	switch action {
+	case constants.ActionPlayPause:
+		return ownTone.Toggle(ctx)
+	case constants.ActionNext:
+		return ownTone.Next(ctx)
+	case constants.ActionPrevious:
+		return ownTone.Previous(ctx)
+	case constants.ActionVolumeUp:
+		return ownTone.ChangeVolume(ctx, volumeStep)
+	case constants.ActionVolumeDown:
+		return ownTone.ChangeVolume(ctx, -volumeStep)
+	case constants.ActionShuffle:
+		status, err := ownTone.GetPlayerStatus(ctx)
+		if err != nil {
+			return err
+		}
+		return ownTone.SetShuffle(ctx, !status.Shuffle)
+	case constants.ActionStop:
+		return ownTone.Stop(ctx)
+	case constants.ActionSnooze:
+		_, err := alarmChecker.Snooze(ctx)
+		return err
+	case constants.ActionStopAlarm:
+		_, err := alarmChecker.Stop(ctx)
+		return err
+	case constants.ActionSleepTimer:
+		sleepTimer.Start(0)
+		return nil
+	}
+	return fmt.Errorf("unknown card action %q", action)
+}
@@ -0,0 +1,122 @@
+package handlers
+
+import (
+	"net/http"
+	"strconv"
+	"strings"
+
+	"cartophone-server/internal/owntone"
+	"cartophone-server/internal/utils"
+)
+
+// maxSearchLimit is the largest number of search results returned per type
+const maxSearchLimit = 100
+
+// SearchLibraryHandler searches the Owntone library. The query parameters are
+// query, type (comma separated, defaults to all types) and limit.
+func SearchLibraryHandler(ownTone *owntone.Client, w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		utils.LogMessage("ERROR", "Invalid request method for SearchLibraryHandler", map[string]string{"method": r.Method})
+		utils.WriteJSONResponse(w, http.StatusMethodNotAllowed, map[string]string{"error": "Invalid request method"})
+		return
+	}
+
+	params := r.URL.Query()
+	query := strings.TrimSpace(params.Get("query"))
+	if query == "" {
+		utils.LogMessage("ERROR", "Missing query in SearchLibraryHandler request", nil)
+		utils.WriteJSONResponse(w, http.StatusBadRequest, map[string]string{"error": "query is required"})
+		return
+	}
+
+	types := owntone.SearchTypes
+	if param := params.Get("type"); param != "" {
+		types = strings.Split(param, ",")
+		for _, searchType := range types {
+			if !isSearchType(searchType) {
+				utils.LogMessage("ERROR", "Invalid search type in SearchLibraryHandler request", searchType)
+				utils.WriteJSONResponse(w, http.StatusBadRequest, map[string]string{"error": "type must list tracks, artists, albums or playlists"})
+				return
+			}
+		}
+	}
+
+	limit := 0
+	if param := params.Get("limit"); param != "" {
+		var err error
+		if limit, err = strconv.Atoi(param); err != nil || limit <= 0 || limit > maxSearchLimit {
+			utils.WriteJSONResponse(w, http.StatusBadRequest, map[string]string{"error": "limit must be between 1 and 100"})
+			return
+		}
+	}
+
+	results, err := ownTone.Search(r.Context(), query, types, limit)
+	if err != nil {
+		utils.LogMessage("ERROR", "Failed to search Owntone library", map[string]string{"error": err.Error()})
+		utils.WriteJSONResponse(w, http.StatusInternalServerError, map[string]string{"error": "Failed to search library"})
+		return
+	}
+
+	utils.LogMessage("INFO", "Owntone library searched successfully", map[string]interface{}{"query": query, "types": types})
+	utils.WriteJSONResponse(w, http.StatusOK, results)
+}
+
+// isSearchType reports whether searchType is one of owntone.SearchTypes
+func isSearchType(searchType string) bool {
+	for _, known := range owntone.SearchTypes {
+		if searchType == known {
+			return true
+		}
+	}
+	return false
+}
+
+// ArtistAlbumsHandler lists the albums of the artist given by the id query parameter
+func ArtistAlbumsHandler(ownTone *owntone.Client, w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		utils.LogMessage("ERROR", "Invalid request method for ArtistAlbumsHandler", map[string]string{"method": r.Method})
+		utils.WriteJSONResponse(w, http.StatusMethodNotAllowed, map[string]string{"error": "Invalid request method"})
+		return
+	}
+
+	artistID := r.URL.Query().Get("id")
+	if artistID == "" {
+		utils.WriteJSONResponse(w, http.StatusBadRequest, map[string]string{"error": "id is required"})
+		return
+	}
+
+	albums, err := ownTone.ArtistAlbums(r.Context(), artistID)
+	if err != nil {
+		utils.LogMessage("ERROR", "Failed to fetch artist albums", map[string]string{"error": err.Error()})
+		utils.WriteJSONResponse(w, http.StatusInternalServerError, map[string]string{"error": "Failed to fetch artist albums"})
+		return
+	}
+
+	utils.LogMessage("INFO", "Artist albums fetched successfully", map[string]interface{}{"artistId": artistID, "count": len(albums)})
+	utils.WriteJSONResponse(w, http.StatusOK, albums)
+}
+
+// AlbumTracksHandler lists the tracks of the album given by the id query parameter
+func AlbumTracksHandler(ownTone *owntone.Client, w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		utils.LogMessage("ERROR", "Invalid request method for AlbumTracksHandler", map[string]string{"method": r.Method})
+		utils.WriteJSONResponse(w, http.StatusMethodNotAllowed, map[string]string{"error": "Invalid request method"})
+		return
+	}
+
+	albumID := r.URL.Query().Get("id")
+	if albumID == "" {
+		utils.WriteJSONResponse(w, http.StatusBadRequest, map[string]string{"error": "id is required"})
+		return
+	}
+
+	tracks, err := ownTone.AlbumTracks(r.Context(), albumID)
+	if err != nil {
+		utils.LogMessage("ERROR", "Failed to fetch album tracks", map[string]string{"error": err.Error()})
+		utils.WriteJSONResponse(w, http.StatusInternalServerError, map[string]string{"error": "Failed to fetch album tracks"})
+		return
+	}
+
+	utils.LogMessage("INFO", "Album tracks fetched successfully", map[string]interface{}{"albumId": albumID, "count": len(tracks)})
+	utils.WriteJSONResponse(w, http.StatusOK, tracks)
+}
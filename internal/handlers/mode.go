@@ -1,21 +1,60 @@
 package handlers
 
 import (
+	"context"
 	"sync"
 
+	"cartophone-server/internal/alarms"
 	"cartophone-server/internal/constants"
+	"cartophone-server/internal/nfc"
+	"cartophone-server/internal/owntone"
+	"cartophone-server/internal/pocketbase"
+	"cartophone-server/internal/sleeptimer"
 	"cartophone-server/internal/utils"
 )
 
+// playbackState tracks the card whose playlist is currently playing, so that
+// lifting the card pauses playback and putting it back resumes it
+type playbackState struct {
+	uid    string
+	cardID string
+	paused bool
+	queue  uint32 // Owntone queue generation the card's playlist was queued in
+}
+
+// readMode handles the cards placed on and removed from the reader in read mode
+type readMode struct {
+	pocketBase      *pocketbase.Client
+	ownTone         *owntone.Client
+	alarmChecker    *alarms.Checker
+	alarmCardAction string // One of the constants.AlarmCard* values
+	sleepTimer      *sleeptimer.Timer
+
+	playback playbackState
+}
+
 // StartModeManager manages the application's mode of operation.
 func StartModeManager(
 	modeSwitch <-chan string,
-	cardDetectedChan <-chan string,
+	cardDetectedChan <-chan nfc.Event,
 	currentMode *string,
 	modeLock *sync.Mutex,
-	pocketBaseURL string,
+	pocketBase *pocketbase.Client,
+	ownTone *owntone.Client,
+	alarmChecker *alarms.Checker,
+	alarmCardAction string,
+	sleepTimer *sleeptimer.Timer,
 ) {
 	go func() {
+		ctx := context.Background()
+		read := &readMode{
+			pocketBase:      pocketBase,
+			ownTone:         ownTone,
+			alarmChecker:    alarmChecker,
+			alarmCardAction: alarmCardAction,
+			sleepTimer:      sleepTimer,
+		}
+
 		for {
 			select {
 			case mode := <-modeSwitch:
@@ -34,19 +73,143 @@ func StartModeManager(
 				}
 				modeLock.Unlock()
 
-			case uid := <-cardDetectedChan:
+			case event := <-cardDetectedChan:
 				modeLock.Lock()
 				if *currentMode == constants.ReadMode {
-					utils.LogMessage("INFO", "Card detected in Read Mode", map[string]interface{}{"uid": uid})
-					HandleReadAction(uid, pocketBaseURL) // Direct call to HandleReadAction
+					switch event.Type {
+					case nfc.CardPlaced:
+						utils.LogMessage("INFO", "Card placed in Read Mode", map[string]interface{}{"uid": event.UID})
+						read.cardPlaced(ctx, event.UID)
+					case nfc.CardRemoved:
+						utils.LogMessage("INFO", "Card removed in Read Mode", map[string]interface{}{"uid": event.UID})
+						read.cardRemoved(ctx, event.UID)
+					}
 				} else {
 					utils.LogMessage("INFO", "Card ignored because of current mode", map[string]interface{}{
-						"uid":  uid,
-						"mode": *currentMode,
+						"uid":   event.UID,
+						"event": event.Type,
+						"mode":  *currentMode,
 					})
 				}
 				modeLock.Unlock()
 			}
 		}
 	}()
-}
\ No newline at end of file
+}
+
+// cardPlaced resumes playback when the paused card is put back, and plays
+// the card's playlist otherwise. While an alarm rings, the card may snooze or
+// stop it instead.
+func (m *readMode) cardPlaced(ctx context.Context, uid string) {
+	ringing := m.alarmChecker.CheckRinging(ctx)
+
+	m.checkQueue()
+	p := &m.playback
+	if !ringing && p.paused && p.uid == uid {
+		if err := m.ownTone.Play(ctx); err != nil {
+			utils.LogMessage("ERROR", "Failed to resume playback", map[string]interface{}{"uid": uid, "error": err.Error()})
+			return
+		}
+		p.paused = false
+		utils.LogMessage("ACTION", "Resumed playback", map[string]interface{}{"uid": uid})
+		return
+	}
+
+	// Check if the card exists in PocketBase
+	card, err := m.pocketBase.CheckCard(ctx, uid)
+	if err != nil {
+		logScanOutcome(ScanOutcome{UID: uid}.fail(ScanStepCheckCard, err))
+	}
+	if ringing && m.handleRingingAlarm(ctx, uid, card) {
+		return
+	}
+	if err != nil {
+		return
+	}
+
+	// Another card interrupts the playing one, remember where it stopped
+	if p.uid != "" && !p.paused {
+		saveCardPosition(ctx, p.cardID, p.queue, m.pocketBase, m.ownTone)
+	}
+
+	outcome := HandleReadAction(ctx, uid, card, m.pocketBase, m.ownTone, m.alarmChecker, m.sleepTimer)
+	logScanOutcome(outcome)
+	if outcome.Status == ScanStatusPlaying {
+		*p = playbackState{uid: uid, cardID: outcome.CardID, queue: m.ownTone.QueueGeneration()}
+
+		// The card's playlist replaced the alarm playlist
+		m.alarmChecker.Dismiss()
+	}
+}
+
+// handleRingingAlarm applies the configured alarm card action to a card
+// placed while an alarm rings. The card is nil when it is unknown or could not
+// be checked. It returns false when the card must be handled as usual: alarm
+// command cards, or when cards do not control alarms.
+func (m *readMode) handleRingingAlarm(ctx context.Context, uid string, card *pocketbase.Card) bool {
+	if card != nil && (card.Action == constants.ActionSnooze || card.Action == constants.ActionStopAlarm) {
+		return false
+	}
+
+	switch m.alarmCardAction {
+	case constants.AlarmCardSnooze:
+		if _, err := m.alarmChecker.Snooze(ctx); err != nil {
+			utils.LogMessage("ERROR", "Failed to snooze alarm with card", map[string]interface{}{"uid": uid, "error": err.Error()})
+		} else {
+			utils.LogMessage("ACTION", "Alarm snoozed with card", map[string]interface{}{"uid": uid})
+		}
+		return true
+	case constants.AlarmCardStop:
+		if _, err := m.alarmChecker.Stop(ctx); err != nil {
+			utils.LogMessage("ERROR", "Failed to stop alarm with card", map[string]interface{}{"uid": uid, "error": err.Error()})
+		} else {
+			utils.LogMessage("ACTION", "Alarm stopped with card", map[string]interface{}{"uid": uid})
+		}
+		return true
+	}
+	return false
+}
+
+// cardRemoved pauses playback when the playing card is lifted
+func (m *readMode) cardRemoved(ctx context.Context, uid string) {
+	m.checkQueue()
+	p := &m.playback
+	if p.uid != uid || p.paused {
+		return
+	}
+
+	if err := m.ownTone.Pause(ctx); err != nil {
+		utils.LogMessage("ERROR", "Failed to pause playback", map[string]interface{}{"uid": uid, "error": err.Error()})
+		return
+	}
+	p.paused = true
+	utils.LogMessage("ACTION", "Paused playback", map[string]interface{}{"uid": uid})
+
+	saveCardPosition(ctx, p.cardID, p.queue, m.pocketBase, m.ownTone)
+}
+
+// checkQueue forgets the card playback once something else replaced the
+// queue, such as an alarm or the queue endpoints, so that lifting the card
+// does not pause the new queue and putting it back does not resume it
+func (m *readMode) checkQueue() {
+	p := &m.playback
+	if p.uid == "" || p.queue == m.ownTone.QueueGeneration() {
+		return
+	}
+	utils.LogMessage("INFO", "Queue replaced since the card started playing", map[string]interface{}{"uid": p.uid})
+	*p = playbackState{}
+}
+
+// logScanOutcome reports the outcome of a read action
+func logScanOutcome(outcome ScanOutcome) {
+	switch outcome.Status {
+	case ScanStatusPlaying:
+		utils.LogMessage("ACTION", "Playing playlist", outcome)
+	case ScanStatusCommand:
+		utils.LogMessage("ACTION", "Ran card command", outcome)
+	case ScanStatusFailed:
+		utils.LogMessage("ERROR", "Failed to handle scanned card", outcome)
+	default:
+		utils.LogMessage("INFO", "Card scanned without playlist", outcome)
+	}
+}
@@ -0,0 +1,162 @@
+package handlers
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+
+	"cartophone-server/internal/alarms"
+	"cartophone-server/internal/owntone"
+	"cartophone-server/internal/pocketbase"
+)
+
+// fakeServer answers as both Owntone and PocketBase, with a card playing the
+// second track of its playlist, and records the requests it receives
+type fakeServer struct {
+	mu       sync.Mutex
+	requests []string
+}
+
+func (f *fakeServer) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	request := r.Method + " " + r.URL.Path
+	f.mu.Lock()
+	f.requests = append(f.requests, request)
+	f.mu.Unlock()
+
+	w.Header().Set("Content-Type", "application/json")
+	switch request {
+	case "GET /api/collections/cards/records":
+		w.Write([]byte(`{"page":1,"totalPages":1,"items":[{"id":"card1","uid":"04a1","playlistId":"playlist1"}]}`))
+	case "GET /api/collections/playlists/records/playlist1":
+		w.Write([]byte(`{"id":"playlist1","name":"Stories","uri":"library:album:1"}`))
+	case "GET /api/player":
+		w.Write([]byte(`{"state":"play","item_id":2,"item_progress_ms":1000}`))
+	case "GET /api/queue":
+		w.Write([]byte(`{"items":[{"id":1,"position":0},{"id":2,"position":1}]}`))
+	default:
+		w.WriteHeader(http.StatusNoContent)
+	}
+}
+
+// sent reports whether the server received a request since the last reset
+func (f *fakeServer) sent(request string) bool {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	for _, r := range f.requests {
+		if r == request {
+			return true
+		}
+	}
+	return false
+}
+
+func (f *fakeServer) reset() {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	f.requests = nil
+}
+
+// newTestReadMode returns a read mode talking to a fake server, with card
+// 04a1 playing
+func newTestReadMode(t *testing.T) (*readMode, *fakeServer) {
+	t.Helper()
+
+	fake := &fakeServer{}
+	server := httptest.NewServer(fake)
+	t.Cleanup(server.Close)
+
+	ownTone := owntone.NewClient(server.URL, nil)
+	m := &readMode{
+		pocketBase:   pocketbase.NewClient(server.URL, nil, nil),
+		ownTone:      ownTone,
+		alarmChecker: &alarms.Checker{},
+		playback:     playbackState{uid: "04a1", cardID: "card1", queue: ownTone.QueueGeneration()},
+	}
+	return m, fake
+}
+
+// fireAlarm replaces the queue like a ringing alarm does
+func fireAlarm(t *testing.T, m *readMode) {
+	t.Helper()
+
+	ctx := context.Background()
+	if err := m.ownTone.ClearQueue(ctx); err != nil {
+		t.Fatal(err)
+	}
+	if err := m.ownTone.AddToQueue(ctx, []string{"library:playlist:2"}); err != nil {
+		t.Fatal(err)
+	}
+}
+
+const (
+	pauseRequest      = "PUT /api/player/pause"
+	playRequest       = "PUT /api/player/play"
+	saveCardRequest   = "PATCH /api/collections/cards/records/card1"
+	clearRequest      = "PUT /api/queue/clear"
+	lookupCardRequest = "GET /api/collections/cards/records"
+)
+
+func TestCardLiftPausesAndSavesPosition(t *testing.T) {
+	m, fake := newTestReadMode(t)
+
+	m.cardRemoved(context.Background(), "04a1")
+
+	if !fake.sent(pauseRequest) || !fake.sent(saveCardRequest) {
+		t.Errorf("lifting the card sent %v, want a pause and the saved position", fake.requests)
+	}
+	if !m.playback.paused {
+		t.Error("card playback is not paused")
+	}
+}
+
+func TestCardLiftAfterAlarm(t *testing.T) {
+	m, fake := newTestReadMode(t)
+
+	fireAlarm(t, m)
+	fake.reset()
+	m.cardRemoved(context.Background(), "04a1")
+
+	if fake.sent(pauseRequest) {
+		t.Error("lifting the card paused the alarm")
+	}
+	if fake.sent(saveCardRequest) {
+		t.Error("lifting the card saved the alarm position on the card")
+	}
+	if m.playback != (playbackState{}) {
+		t.Errorf("card playback %+v kept after the alarm replaced the queue", m.playback)
+	}
+}
+
+func TestCardPutBackAfterAlarm(t *testing.T) {
+	m, fake := newTestReadMode(t)
+
+	m.cardRemoved(context.Background(), "04a1")
+	fireAlarm(t, m)
+	fake.reset()
+	m.cardPlaced(context.Background(), "04a1")
+
+	// The card playlist is queued again instead of resuming the alarm
+	if !fake.sent(lookupCardRequest) || !fake.sent(clearRequest) || !fake.sent(playRequest) {
+		t.Errorf("putting the card back sent %v, want its playlist queued again", fake.requests)
+	}
+	if m.playback.paused || m.playback.queue != m.ownTone.QueueGeneration() {
+		t.Errorf("card playback %+v does not follow the new queue", m.playback)
+	}
+}
+
+func TestSaveCardPositionAfterQueueReplaced(t *testing.T) {
+	m, fake := newTestReadMode(t)
+
+	queue := m.ownTone.QueueGeneration()
+	fireAlarm(t, m)
+	fake.reset()
+	saveCardPosition(context.Background(), "card1", queue, m.pocketBase, m.ownTone)
+
+	if fake.sent(saveCardRequest) {
+		t.Error("the alarm position was saved on the card")
+	}
+}
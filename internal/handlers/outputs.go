@@ -0,0 +1,160 @@
+package handlers
+
+import (
+	"encoding/json"
+	"net/http"
+
+	"cartophone-server/internal/owntone"
+	"cartophone-server/internal/utils"
+)
+
+// VolumeHandler returns (GET) or changes (POST) the master volume, or the
+// volume of a single output when an output ID is given
+func VolumeHandler(ownTone *owntone.Client, w http.ResponseWriter, r *http.Request) {
+	switch r.Method {
+	case http.MethodGet:
+		status, err := ownTone.GetPlayerStatus(r.Context())
+		if err != nil {
+			utils.LogMessage("ERROR", "Failed to fetch player status", map[string]string{"error": err.Error()})
+			utils.WriteJSONResponse(w, http.StatusInternalServerError, map[string]string{"error": err.Error()})
+			return
+		}
+		utils.WriteJSONResponse(w, http.StatusOK, map[string]int{"volume": status.Volume})
+
+	case http.MethodPost:
+		var payload struct {
+			Volume   *int   `json:"volume"`   // Absolute volume (0-100)
+			Step     *int   `json:"step"`     // Change of the master volume, may be negative
+			OutputID string `json:"outputId"` // Only change this output
+		}
+		if err := json.NewDecoder(r.Body).Decode(&payload); err != nil {
+			utils.LogMessage("ERROR", "Invalid request payload for VolumeHandler", nil)
+			utils.WriteJSONResponse(w, http.StatusBadRequest, map[string]string{"error": "Invalid request body"})
+			return
+		}
+
+		var err error
+		switch {
+		case payload.Volume != nil && payload.Step == nil:
+			if *payload.Volume < 0 || *payload.Volume > 100 {
+				utils.WriteJSONResponse(w, http.StatusBadRequest, map[string]string{"error": "Volume must be between 0 and 100"})
+				return
+			}
+			if payload.OutputID != "" {
+				err = ownTone.SetOutputVolume(r.Context(), payload.OutputID, *payload.Volume)
+			} else {
+				err = ownTone.SetVolume(r.Context(), *payload.Volume)
+			}
+		case payload.Step != nil && payload.Volume == nil && payload.OutputID == "":
+			err = ownTone.ChangeVolume(r.Context(), *payload.Step)
+		default:
+			utils.LogMessage("ERROR", "VolumeHandler needs either volume or step", payload)
+			utils.WriteJSONResponse(w, http.StatusBadRequest, map[string]string{"error": "Either volume, or step without outputId, is required"})
+			return
+		}
+		if err != nil {
+			utils.LogMessage("ERROR", "Failed to change volume", map[string]string{"error": err.Error()})
+			utils.WriteJSONResponse(w, http.StatusInternalServerError, map[string]string{"error": err.Error()})
+			return
+		}
+
+		utils.LogMessage("INFO", "Volume changed successfully", payload)
+		utils.WriteJSONResponse(w, http.StatusOK, map[string]string{"message": "Volume changed"})
+
+	default:
+		utils.LogMessage("ERROR", "Invalid request method for VolumeHandler", map[string]string{"method": r.Method})
+		utils.WriteJSONResponse(w, http.StatusMethodNotAllowed, map[string]string{"error": "Invalid request method"})
+	}
+}
+
+// ListOutputsHandler lists the Owntone outputs
+func ListOutputsHandler(ownTone *owntone.Client, w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		utils.LogMessage("ERROR", "Invalid request method for ListOutputsHandler", map[string]string{"method": r.Method})
+		utils.WriteJSONResponse(w, http.StatusMethodNotAllowed, map[string]string{"error": "Invalid request method"})
+		return
+	}
+
+	outputs, err := ownTone.ListOutputs(r.Context())
+	if err != nil {
+		utils.LogMessage("ERROR", "Failed to fetch Owntone outputs", map[string]string{"error": err.Error()})
+		utils.WriteJSONResponse(w, http.StatusInternalServerError, map[string]string{"error": "Failed to fetch outputs"})
+		return
+	}
+
+	utils.LogMessage("INFO", "Owntone outputs fetched successfully", map[string]interface{}{"count": len(outputs)})
+	utils.WriteJSONResponse(w, http.StatusOK, outputs)
+}
+
+// UpdateOutputHandler enables, disables or sets the volume of an output
+func UpdateOutputHandler(ownTone *owntone.Client, w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPatch {
+		utils.LogMessage("ERROR", "Invalid request method for UpdateOutputHandler", map[string]string{"method": r.Method})
+		utils.WriteJSONResponse(w, http.StatusMethodNotAllowed, map[string]string{"error": "Invalid request method"})
+		return
+	}
+
+	var payload struct {
+		ID       string `json:"id"`
+		Selected *bool  `json:"selected"`
+		Volume   *int   `json:"volume"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&payload); err != nil {
+		utils.LogMessage("ERROR", "Invalid request payload for UpdateOutputHandler", nil)
+		utils.WriteJSONResponse(w, http.StatusBadRequest, map[string]string{"error": "Invalid request body"})
+		return
+	}
+
+	if payload.ID == "" || (payload.Selected == nil && payload.Volume == nil) {
+		utils.LogMessage("ERROR", "Missing output ID or change in UpdateOutputHandler", payload)
+		utils.WriteJSONResponse(w, http.StatusBadRequest, map[string]string{"error": "id and selected or volume are required"})
+		return
+	}
+	if payload.Volume != nil && (*payload.Volume < 0 || *payload.Volume > 100) {
+		utils.WriteJSONResponse(w, http.StatusBadRequest, map[string]string{"error": "Volume must be between 0 and 100"})
+		return
+	}
+
+	change := owntone.OutputChange{Selected: payload.Selected, Volume: payload.Volume}
+	if err := ownTone.UpdateOutput(r.Context(), payload.ID, change); err != nil {
+		utils.LogMessage("ERROR", "Failed to update Owntone output", map[string]string{"error": err.Error()})
+		utils.WriteJSONResponse(w, http.StatusInternalServerError, map[string]string{"error": "Failed to update output"})
+		return
+	}
+
+	utils.LogMessage("INFO", "Owntone output updated successfully", payload)
+	utils.WriteJSONResponse(w, http.StatusOK, map[string]string{"message": "Output updated successfully"})
+}
+
+// SelectOutputsHandler enables the given outputs and disables all the others
+func SelectOutputsHandler(ownTone *owntone.Client, w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPut {
+		utils.LogMessage("ERROR", "Invalid request method for SelectOutputsHandler", map[string]string{"method": r.Method})
+		utils.WriteJSONResponse(w, http.StatusMethodNotAllowed, map[string]string{"error": "Invalid request method"})
+		return
+	}
+
+	var payload struct {
+		IDs []string `json:"ids"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&payload); err != nil {
+		utils.LogMessage("ERROR", "Invalid request payload for SelectOutputsHandler", nil)
+		utils.WriteJSONResponse(w, http.StatusBadRequest, map[string]string{"error": "Invalid request body"})
+		return
+	}
+
+	if len(payload.IDs) == 0 {
+		utils.LogMessage("ERROR", "Empty output IDs in SelectOutputsHandler request payload", nil)
+		utils.WriteJSONResponse(w, http.StatusBadRequest, map[string]string{"error": "IDs array cannot be empty"})
+		return
+	}
+
+	if err := ownTone.SelectOutputs(r.Context(), payload.IDs); err != nil {
+		utils.LogMessage("ERROR", "Failed to select Owntone outputs", map[string]string{"error": err.Error()})
+		utils.WriteJSONResponse(w, http.StatusInternalServerError, map[string]string{"error": "Failed to select outputs"})
+		return
+	}
+
+	utils.LogMessage("INFO", "Owntone outputs selected successfully", payload)
+	utils.WriteJSONResponse(w, http.StatusOK, map[string]string{"message": "Outputs selected successfully"})
+}
@@ -2,33 +2,83 @@ package handlers
 
 import (
 	"encoding/json"
+	"errors"
 	"net/http"
+	"time"
 
 	"cartophone-server/internal/owntone"
+	"cartophone-server/internal/sleeptimer"
 	"cartophone-server/internal/utils"
 )
 
+// playerStatus is the status of the OwnTone player with the running sleep timer
+type playerStatus struct {
+	*owntone.PlayerStatus
+	SleepTimer *sleeptimer.Status `json:"sleepTimer"`
+}
+
 // PlayerStatusHandler retrieves the status of the OwnTone player
-func PlayerStatusHandler(ownToneBaseURL string, w http.ResponseWriter, r *http.Request) {
+func PlayerStatusHandler(ownTone *owntone.Client, sleepTimer *sleeptimer.Timer, w http.ResponseWriter, r *http.Request) {
 	if r.Method != http.MethodGet {
 		utils.LogMessage("ERROR", "Invalid request method for PlayerStatusHandler", map[string]string{"method": r.Method})
 		utils.WriteJSONResponse(w, http.StatusMethodNotAllowed, map[string]string{"error": "Invalid request method"})
 		return
 	}
 
-	status, err := owntone.GetPlayerStatus(ownToneBaseURL)
+	status, err := ownTone.GetPlayerStatus(r.Context())
 	if err != nil {
 		utils.LogMessage("ERROR", "Failed to fetch player status", map[string]string{"error": err.Error()})
 		utils.WriteJSONResponse(w, http.StatusInternalServerError, map[string]string{"error": err.Error()})
 		return
 	}
 
-	utils.LogMessage("INFO", "Player status fetched successfully", status)
-	utils.WriteJSONResponse(w, http.StatusOK, status)
+	response := playerStatus{PlayerStatus: status, SleepTimer: sleepTimer.Status()}
+	utils.LogMessage("INFO", "Player status fetched successfully", response)
+	utils.WriteJSONResponse(w, http.StatusOK, response)
+}
+
+// SleepTimerHandler starts (POST), cancels (DELETE) or shows (GET) the sleep timer
+func SleepTimerHandler(sleepTimer *sleeptimer.Timer, w http.ResponseWriter, r *http.Request) {
+	switch r.Method {
+	case http.MethodGet:
+		utils.WriteJSONResponse(w, http.StatusOK, map[string]interface{}{"sleepTimer": sleepTimer.Status()})
+
+	case http.MethodPost:
+		var payload struct {
+			Minutes int `json:"minutes"` // 0 uses the configured duration
+		}
+		if r.ContentLength != 0 {
+			if err := json.NewDecoder(r.Body).Decode(&payload); err != nil {
+				utils.LogMessage("ERROR", "Failed to decode request body for SleepTimerHandler", map[string]string{"error": err.Error()})
+				utils.WriteJSONResponse(w, http.StatusBadRequest, map[string]string{"error": "Invalid request body"})
+				return
+			}
+		}
+		if payload.Minutes < 0 {
+			utils.LogMessage("ERROR", "Invalid sleep timer duration", payload)
+			utils.WriteJSONResponse(w, http.StatusBadRequest, map[string]string{"error": "Minutes cannot be negative"})
+			return
+		}
+
+		status := sleepTimer.Start(time.Duration(payload.Minutes) * time.Minute)
+		utils.WriteJSONResponse(w, http.StatusOK, map[string]interface{}{"sleepTimer": status})
+
+	case http.MethodDelete:
+		if err := sleepTimer.Cancel(); errors.Is(err, sleeptimer.ErrNotRunning) {
+			utils.LogMessage("INFO", "Sleep timer cancel requested while none is running", nil)
+			utils.WriteJSONResponse(w, http.StatusConflict, map[string]string{"error": "No sleep timer is running"})
+			return
+		}
+		utils.WriteJSONResponse(w, http.StatusOK, map[string]string{"message": "Sleep timer cancelled"})
+
+	default:
+		utils.LogMessage("ERROR", "Invalid request method for SleepTimerHandler", map[string]string{"method": r.Method})
+		utils.WriteJSONResponse(w, http.StatusMethodNotAllowed, map[string]string{"error": "Invalid request method"})
+	}
 }
 
 // PlayHandler triggers the play action on the Owntone player
-func PlayHandler(baseURL string, w http.ResponseWriter, r *http.Request) {
+func PlayHandler(ownTone *owntone.Client, w http.ResponseWriter, r *http.Request) {
 	if r.Method != http.MethodPost {
 		utils.LogMessage("ERROR", "Invalid request method for PlayHandler", map[string]string{"method": r.Method})
 		utils.WriteJSONResponse(w, http.StatusMethodNotAllowed, map[string]string{"error": "Invalid request method"})
@@ -37,7 +87,7 @@ func PlayHandler(baseURL string, w http.ResponseWriter, r *http.Request) {
 
 	utils.LogMessage("INFO", "Received request to play", nil)
 
-	err := owntone.Play(baseURL)
+	err := ownTone.Play(r.Context())
 	if err != nil {
 		utils.LogMessage("ERROR", "Failed to play Owntone", map[string]string{"error": err.Error()})
 		utils.WriteJSONResponse(w, http.StatusInternalServerError, map[string]string{"error": err.Error()})
@@ -49,7 +99,7 @@ func PlayHandler(baseURL string, w http.ResponseWriter, r *http.Request) {
 }
 
 // PauseHandler triggers the pause action on the Owntone player
-func PauseHandler(baseURL string, w http.ResponseWriter, r *http.Request) {
+func PauseHandler(ownTone *owntone.Client, w http.ResponseWriter, r *http.Request) {
 	if r.Method != http.MethodPost {
 		utils.LogMessage("ERROR", "Invalid request method for PauseHandler", map[string]string{"method": r.Method})
 		utils.WriteJSONResponse(w, http.StatusMethodNotAllowed, map[string]string{"error": "Invalid request method"})
@@ -58,7 +108,7 @@ func PauseHandler(baseURL string, w http.ResponseWriter, r *http.Request) {
 
 	utils.LogMessage("INFO", "Received request to pause", nil)
 
-	err := owntone.Pause(baseURL)
+	err := ownTone.Pause(r.Context())
 	if err != nil {
 		utils.LogMessage("ERROR", "Failed to pause Owntone", map[string]string{"error": err.Error()})
 		utils.WriteJSONResponse(w, http.StatusInternalServerError, map[string]string{"error": err.Error()})
@@ -70,14 +120,14 @@ func PauseHandler(baseURL string, w http.ResponseWriter, r *http.Request) {
 }
 
 // ListQueueHandler lists the current Owntone player queue
-func ListQueueHandler(baseURL string, w http.ResponseWriter, r *http.Request) {
+func ListQueueHandler(ownTone *owntone.Client, w http.ResponseWriter, r *http.Request) {
 	if r.Method != http.MethodGet {
 		utils.LogMessage("ERROR", "Invalid request method for ListQueueHandler", map[string]string{"method": r.Method})
 		utils.WriteJSONResponse(w, http.StatusMethodNotAllowed, map[string]string{"error": "Invalid request method"})
 		return
 	}
 
-	queue, err := owntone.FetchQueue(baseURL)
+	queue, err := ownTone.FetchQueue(r.Context())
 	if err != nil {
 		utils.LogMessage("ERROR", "Failed to fetch Owntone queue", map[string]string{"error": err.Error()})
 		utils.WriteJSONResponse(w, http.StatusInternalServerError, map[string]string{"error": "Failed to fetch queue"})
@@ -89,14 +139,14 @@ func ListQueueHandler(baseURL string, w http.ResponseWriter, r *http.Request) {
 }
 
 // ClearQueueHandler clears the Owntone queue
-func ClearQueueHandler(baseURL string, w http.ResponseWriter, r *http.Request) {
+func ClearQueueHandler(ownTone *owntone.Client, w http.ResponseWriter, r *http.Request) {
 	if r.Method != http.MethodPut {
 		utils.LogMessage("ERROR", "Invalid request method for ClearQueueHandler", map[string]string{"method": r.Method})
 		utils.WriteJSONResponse(w, http.StatusMethodNotAllowed, map[string]string{"error": "Invalid request method"})
 		return
 	}
 
-	err := owntone.ClearQueue(baseURL)
+	err := ownTone.ClearQueue(r.Context())
 	if err != nil {
 		utils.LogMessage("ERROR", "Failed to clear Owntone queue", map[string]string{"error": err.Error()})
 		utils.WriteJSONResponse(w, http.StatusInternalServerError, map[string]string{"error": "Failed to clear queue"})
@@ -108,7 +158,7 @@ func ClearQueueHandler(baseURL string, w http.ResponseWriter, r *http.Request) {
 }
 
 // AddToQueueHandler adds items to the Owntone queue
-func AddToQueueHandler(baseURL string, w http.ResponseWriter, r *http.Request) {
+func AddToQueueHandler(ownTone *owntone.Client, w http.ResponseWriter, r *http.Request) {
 	if r.Method != http.MethodPost {
 		utils.LogMessage("ERROR", "Invalid request method for AddToQueueHandler", map[string]string{"method": r.Method})
 		utils.WriteJSONResponse(w, http.StatusMethodNotAllowed, map[string]string{"error": "Invalid request method"})
@@ -130,7 +180,7 @@ func AddToQueueHandler(baseURL string, w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	err := owntone.AddToQueue(baseURL, payload.Uris)
+	err := ownTone.AddToQueue(r.Context(), payload.Uris)
 	if err != nil {
 		utils.LogMessage("ERROR", "Failed to add items to Owntone queue", map[string]string{"error": err.Error()})
 		utils.WriteJSONResponse(w, http.StatusInternalServerError, map[string]string{"error": "Failed to add items to queue"})
@@ -139,4 +189,132 @@ func AddToQueueHandler(baseURL string, w http.ResponseWriter, r *http.Request) {
 
 	utils.LogMessage("INFO", "Owntone queue items added successfully", map[string]interface{}{"uris": payload.Uris})
 	utils.WriteJSONResponse(w, http.StatusOK, map[string]string{"message": "Items added to queue successfully"})
+}
+
+// RemoveQueueItemHandler removes an item from the Owntone queue
+func RemoveQueueItemHandler(ownTone *owntone.Client, w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodDelete {
+		utils.LogMessage("ERROR", "Invalid request method for RemoveQueueItemHandler", map[string]string{"method": r.Method})
+		utils.WriteJSONResponse(w, http.StatusMethodNotAllowed, map[string]string{"error": "Invalid request method"})
+		return
+	}
+
+	var payload struct {
+		ID int `json:"id"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&payload); err != nil || payload.ID <= 0 {
+		utils.LogMessage("ERROR", "Invalid request payload for RemoveQueueItemHandler", nil)
+		utils.WriteJSONResponse(w, http.StatusBadRequest, map[string]string{"error": "A queue item id is required"})
+		return
+	}
+
+	if err := ownTone.RemoveQueueItem(r.Context(), payload.ID); err != nil {
+		utils.LogMessage("ERROR", "Failed to remove Owntone queue item", map[string]string{"error": err.Error()})
+		utils.WriteJSONResponse(w, http.StatusInternalServerError, map[string]string{"error": "Failed to remove queue item"})
+		return
+	}
+
+	utils.LogMessage("INFO", "Owntone queue item removed successfully", payload)
+	utils.WriteJSONResponse(w, http.StatusOK, map[string]string{"message": "Queue item removed successfully"})
+}
+
+// MoveQueueItemHandler moves an item of the Owntone queue to a new position
+func MoveQueueItemHandler(ownTone *owntone.Client, w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPut {
+		utils.LogMessage("ERROR", "Invalid request method for MoveQueueItemHandler", map[string]string{"method": r.Method})
+		utils.WriteJSONResponse(w, http.StatusMethodNotAllowed, map[string]string{"error": "Invalid request method"})
+		return
+	}
+
+	var payload struct {
+		ID       int  `json:"id"`
+		Position *int `json:"position"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&payload); err != nil || payload.ID <= 0 || payload.Position == nil || *payload.Position < 0 {
+		utils.LogMessage("ERROR", "Invalid request payload for MoveQueueItemHandler", nil)
+		utils.WriteJSONResponse(w, http.StatusBadRequest, map[string]string{"error": "A queue item id and a position are required"})
+		return
+	}
+
+	if err := ownTone.MoveQueueItem(r.Context(), payload.ID, *payload.Position); err != nil {
+		utils.LogMessage("ERROR", "Failed to move Owntone queue item", map[string]string{"error": err.Error()})
+		utils.WriteJSONResponse(w, http.StatusInternalServerError, map[string]string{"error": "Failed to move queue item"})
+		return
+	}
+
+	utils.LogMessage("INFO", "Owntone queue item moved successfully", payload)
+	utils.WriteJSONResponse(w, http.StatusOK, map[string]string{"message": "Queue item moved successfully"})
+}
+
+// InsertIntoQueueHandler adds items to the Owntone queue at a position, or right after the current track
+func InsertIntoQueueHandler(ownTone *owntone.Client, w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		utils.LogMessage("ERROR", "Invalid request method for InsertIntoQueueHandler", map[string]string{"method": r.Method})
+		utils.WriteJSONResponse(w, http.StatusMethodNotAllowed, map[string]string{"error": "Invalid request method"})
+		return
+	}
+
+	var payload struct {
+		Uris     []string `json:"uris"`
+		Position *int     `json:"position"`
+		PlayNext bool     `json:"playNext"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&payload); err != nil {
+		utils.LogMessage("ERROR", "Invalid request payload for InsertIntoQueueHandler", nil)
+		utils.WriteJSONResponse(w, http.StatusBadRequest, map[string]string{"error": "Invalid request body"})
+		return
+	}
+
+	if len(payload.Uris) == 0 {
+		utils.LogMessage("ERROR", "Empty URIs array in InsertIntoQueueHandler request payload", nil)
+		utils.WriteJSONResponse(w, http.StatusBadRequest, map[string]string{"error": "URIs array cannot be empty"})
+		return
+	}
+	if payload.PlayNext == (payload.Position != nil) || (payload.Position != nil && *payload.Position < 0) {
+		utils.LogMessage("ERROR", "InsertIntoQueueHandler needs either a position or playNext", payload)
+		utils.WriteJSONResponse(w, http.StatusBadRequest, map[string]string{"error": "Either a position or playNext is required"})
+		return
+	}
+
+	var err error
+	if payload.PlayNext {
+		err = ownTone.PlayNext(r.Context(), payload.Uris)
+	} else {
+		err = ownTone.InsertIntoQueue(r.Context(), payload.Uris, *payload.Position)
+	}
+	if err != nil {
+		utils.LogMessage("ERROR", "Failed to insert items into Owntone queue", map[string]string{"error": err.Error()})
+		utils.WriteJSONResponse(w, http.StatusInternalServerError, map[string]string{"error": "Failed to insert items into queue"})
+		return
+	}
+
+	utils.LogMessage("INFO", "Owntone queue items inserted successfully", payload)
+	utils.WriteJSONResponse(w, http.StatusOK, map[string]string{"message": "Items inserted into queue successfully"})
+}
+
+// PlayQueueItemHandler starts playback at an item of the Owntone queue
+func PlayQueueItemHandler(ownTone *owntone.Client, w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		utils.LogMessage("ERROR", "Invalid request method for PlayQueueItemHandler", map[string]string{"method": r.Method})
+		utils.WriteJSONResponse(w, http.StatusMethodNotAllowed, map[string]string{"error": "Invalid request method"})
+		return
+	}
+
+	var payload struct {
+		ID int `json:"id"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&payload); err != nil || payload.ID <= 0 {
+		utils.LogMessage("ERROR", "Invalid request payload for PlayQueueItemHandler", nil)
+		utils.WriteJSONResponse(w, http.StatusBadRequest, map[string]string{"error": "A queue item id is required"})
+		return
+	}
+
+	if err := ownTone.PlayQueueItem(r.Context(), payload.ID); err != nil {
+		utils.LogMessage("ERROR", "Failed to play Owntone queue item", map[string]string{"error": err.Error()})
+		utils.WriteJSONResponse(w, http.StatusInternalServerError, map[string]string{"error": err.Error()})
+		return
+	}
+
+	utils.LogMessage("INFO", "Playing Owntone queue item", payload)
+	utils.WriteJSONResponse(w, http.StatusOK, map[string]string{"message": "Playback started"})
 }
\ No newline at end of file
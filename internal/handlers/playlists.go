@@ -0,0 +1,318 @@
+package handlers
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"strings"
+
+	"cartophone-server/internal/owntone"
+	"cartophone-server/internal/pocketbase"
+	"cartophone-server/internal/utils"
+)
+
+// playlistPayload is the body of the playlist create and update requests
+type playlistPayload struct {
+	ID            string   `json:"id"`
+	Name          string   `json:"name"`
+	URI           string   `json:"uri"`  // Single URI, for clients that predate uris
+	URIs          []string `json:"uris"` // Takes precedence over uri
+	Outputs       []string `json:"outputs"`
+	Shuffle       string   `json:"shuffle"`
+	Repeat        string   `json:"repeat"`
+	StartPosition int      `json:"startPosition"`
+	StartOffsetMs int      `json:"startOffsetMs"`
+}
+
+// playlist validates the payload and returns the playlist it describes, or
+// an error message
+func (p playlistPayload) playlist() (pocketbase.Playlist, string) {
+	playlist := pocketbase.Playlist{
+		ID:            p.ID,
+		Name:          strings.TrimSpace(p.Name),
+		Outputs:       p.Outputs,
+		Shuffle:       p.Shuffle,
+		Repeat:        p.Repeat,
+		StartPosition: p.StartPosition,
+		StartOffsetMs: p.StartOffsetMs,
+	}
+
+	uris := p.URIs
+	if len(uris) == 0 && p.URI != "" {
+		uris = []string{p.URI}
+	}
+	for _, uri := range uris {
+		uri = strings.TrimSpace(uri)
+		if uri == "" {
+			return playlist, "Playlist URIs cannot be empty"
+		}
+		playlist.URIs = append(playlist.URIs, uri)
+	}
+
+	switch {
+	case playlist.Name == "":
+		return playlist, "Playlist name is required"
+	case len(playlist.URIs) == 0:
+		return playlist, "At least one playlist URI is required"
+	case p.StartPosition < 0 || p.StartOffsetMs < 0:
+		return playlist, "Start position and offset cannot be negative"
+	}
+	switch p.Shuffle {
+	case "", owntone.ShuffleOn, owntone.ShuffleOff:
+	default:
+		return playlist, "Shuffle must be on or off"
+	}
+	switch p.Repeat {
+	case "", owntone.RepeatOff, owntone.RepeatAll, owntone.RepeatSingle:
+	default:
+		return playlist, "Repeat must be off, all or single"
+	}
+	return playlist, ""
+}
+
+// playlistUpdatePayload is the body of the playlist update request. Fields
+// left out keep their value.
+type playlistUpdatePayload struct {
+	ID            string    `json:"id"`
+	Name          *string   `json:"name"`
+	URI           *string   `json:"uri"`
+	URIs          *[]string `json:"uris"`
+	Outputs       *[]string `json:"outputs"`
+	Shuffle       *string   `json:"shuffle"`
+	Repeat        *string   `json:"repeat"`
+	StartPosition *int      `json:"startPosition"`
+	StartOffsetMs *int      `json:"startOffsetMs"`
+}
+
+// apply returns the playlist payload of the current playlist with the fields
+// of the request changed, along with the JSON names of these fields
+func (p playlistUpdatePayload) apply(current pocketbase.Playlist) (playlistPayload, []string) {
+	merged := playlistPayload{
+		ID:            p.ID,
+		Name:          current.Name,
+		URIs:          current.QueueURIs(),
+		Outputs:       current.Outputs,
+		Shuffle:       current.Shuffle,
+		Repeat:        current.Repeat,
+		StartPosition: current.StartPosition,
+		StartOffsetMs: current.StartOffsetMs,
+	}
+
+	var changed []string
+	if p.Name != nil {
+		merged.Name = *p.Name
+		changed = append(changed, "name")
+	}
+	if p.URIs != nil {
+		merged.URIs = *p.URIs
+		changed = append(changed, "uri", "uris")
+	} else if p.URI != nil {
+		merged.URI, merged.URIs = *p.URI, nil
+		changed = append(changed, "uri", "uris")
+	}
+	if p.Outputs != nil {
+		merged.Outputs = *p.Outputs
+		changed = append(changed, "outputs")
+	}
+	if p.Shuffle != nil {
+		merged.Shuffle = *p.Shuffle
+		changed = append(changed, "shuffle")
+	}
+	if p.Repeat != nil {
+		merged.Repeat = *p.Repeat
+		changed = append(changed, "repeat")
+	}
+	if p.StartPosition != nil {
+		merged.StartPosition = *p.StartPosition
+		changed = append(changed, "startPosition")
+	}
+	if p.StartOffsetMs != nil {
+		merged.StartOffsetMs = *p.StartOffsetMs
+		changed = append(changed, "startOffsetMs")
+	}
+	return merged, changed
+}
+
+// ListPlaylistsHandler lists all playlists
+func ListPlaylistsHandler(pocketBase *pocketbase.Client, w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		utils.WriteJSONResponse(w, http.StatusMethodNotAllowed, map[string]string{"error": "Invalid request method"})
+		utils.LogMessage("ERROR", "Invalid request method for ListPlaylistsHandler", nil)
+		return
+	}
+
+	playlists, err := pocketBase.ListPlaylists(r.Context())
+	if err != nil {
+		utils.WriteJSONResponse(w, http.StatusInternalServerError, map[string]string{"error": "Failed to list playlists"})
+		utils.LogMessage("ERROR", "Failed to list playlists from PocketBase", err.Error())
+		return
+	}
+
+	utils.WriteJSONResponse(w, http.StatusOK, playlists)
+	utils.LogMessage("INFO", "Listed all playlists successfully", map[string]interface{}{"count": len(playlists)})
+}
+
+// CreatePlaylistHandler handles the creation of a new playlist
+func CreatePlaylistHandler(pocketBase *pocketbase.Client, w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		utils.WriteJSONResponse(w, http.StatusMethodNotAllowed, map[string]string{"error": "Invalid request method"})
+		utils.LogMessage("ERROR", "Invalid request method for CreatePlaylistHandler", nil)
+		return
+	}
+
+	var payload playlistPayload
+	if err := json.NewDecoder(r.Body).Decode(&payload); err != nil {
+		utils.WriteJSONResponse(w, http.StatusBadRequest, map[string]string{"error": "Invalid request body"})
+		utils.LogMessage("ERROR", "Failed to decode request body for CreatePlaylistHandler", err.Error())
+		return
+	}
+
+	playlist, message := payload.playlist()
+	if message != "" {
+		utils.WriteJSONResponse(w, http.StatusBadRequest, map[string]string{"error": message})
+		utils.LogMessage("ERROR", "Invalid playlist for CreatePlaylistHandler", payload)
+		return
+	}
+
+	created, err := pocketBase.CreatePlaylist(r.Context(), playlist)
+	if err != nil {
+		utils.WriteJSONResponse(w, http.StatusInternalServerError, map[string]string{"error": "Failed to create playlist"})
+		utils.LogMessage("ERROR", "Failed to create playlist in PocketBase", err.Error())
+		return
+	}
+
+	utils.WriteJSONResponse(w, http.StatusCreated, created)
+	utils.LogMessage("INFO", "Playlist created successfully", created)
+}
+
+// UpdatePlaylistHandler handles changing the name, URIs, outputs and options
+// of a playlist. Only the fields present in the request are changed.
+func UpdatePlaylistHandler(pocketBase *pocketbase.Client, w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPatch {
+		utils.WriteJSONResponse(w, http.StatusMethodNotAllowed, map[string]string{"error": "Invalid request method"})
+		utils.LogMessage("ERROR", "Invalid request method for UpdatePlaylistHandler", nil)
+		return
+	}
+
+	var payload playlistUpdatePayload
+	if err := json.NewDecoder(r.Body).Decode(&payload); err != nil {
+		utils.WriteJSONResponse(w, http.StatusBadRequest, map[string]string{"error": "Invalid request body"})
+		utils.LogMessage("ERROR", "Failed to decode request body for UpdatePlaylistHandler", err.Error())
+		return
+	}
+	if payload.ID == "" {
+		utils.WriteJSONResponse(w, http.StatusBadRequest, map[string]string{"error": "Playlist ID is required"})
+		utils.LogMessage("ERROR", "Missing playlist ID for UpdatePlaylistHandler", nil)
+		return
+	}
+
+	current, err := pocketBase.GetPlaylist(r.Context(), payload.ID)
+	var apiErr *pocketbase.APIError
+	if errors.As(err, &apiErr) && apiErr.StatusCode == http.StatusNotFound {
+		utils.WriteJSONResponse(w, http.StatusNotFound, map[string]string{"error": "Playlist not found"})
+		return
+	} else if err != nil {
+		utils.WriteJSONResponse(w, http.StatusInternalServerError, map[string]string{"error": "Failed to fetch playlist"})
+		utils.LogMessage("ERROR", "Failed to fetch playlist from PocketBase", err.Error())
+		return
+	}
+
+	// Validate the playlist as it will be once updated
+	merged, changed := payload.apply(*current)
+	playlist, message := merged.playlist()
+	if message != "" {
+		utils.WriteJSONResponse(w, http.StatusBadRequest, map[string]string{"error": message})
+		utils.LogMessage("ERROR", "Invalid playlist for UpdatePlaylistHandler", payload)
+		return
+	}
+
+	all := playlist.Fields()
+	fields := make(map[string]interface{}, len(changed))
+	for _, name := range changed {
+		fields[name] = all[name]
+	}
+
+	updated, err := pocketBase.UpdatePlaylist(r.Context(), payload.ID, fields)
+	if err != nil {
+		utils.WriteJSONResponse(w, http.StatusInternalServerError, map[string]string{"error": "Failed to update playlist"})
+		utils.LogMessage("ERROR", "Failed to update playlist in PocketBase", err.Error())
+		return
+	}
+
+	utils.WriteJSONResponse(w, http.StatusOK, updated)
+	utils.LogMessage("INFO", "Playlist updated successfully", updated)
+}
+
+// DeletePlaylistHandler handles the deletion of a playlist. It refuses when
+// cards or alarms still play it, unless cascade is set: the alarms are then
+// deleted and the cards unassigned.
+func DeletePlaylistHandler(pocketBase *pocketbase.Client, w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodDelete {
+		utils.WriteJSONResponse(w, http.StatusMethodNotAllowed, map[string]string{"error": "Invalid request method"})
+		utils.LogMessage("ERROR", "Invalid request method for DeletePlaylistHandler", nil)
+		return
+	}
+
+	var payload struct {
+		ID      string `json:"id"`
+		Cascade bool   `json:"cascade"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&payload); err != nil || payload.ID == "" {
+		utils.WriteJSONResponse(w, http.StatusBadRequest, map[string]string{"error": "Playlist ID is required"})
+		utils.LogMessage("ERROR", "Invalid request body for DeletePlaylistHandler", nil)
+		return
+	}
+
+	cards, err := pocketBase.ListPlaylistCards(r.Context(), payload.ID)
+	if err != nil {
+		utils.WriteJSONResponse(w, http.StatusInternalServerError, map[string]string{"error": "Failed to check playlist cards"})
+		utils.LogMessage("ERROR", "Failed to list playlist cards from PocketBase", err.Error())
+		return
+	}
+	alarms, err := pocketBase.ListPlaylistAlarms(r.Context(), payload.ID)
+	if err != nil {
+		utils.WriteJSONResponse(w, http.StatusInternalServerError, map[string]string{"error": "Failed to check playlist alarms"})
+		utils.LogMessage("ERROR", "Failed to list playlist alarms from PocketBase", err.Error())
+		return
+	}
+
+	references := map[string]interface{}{"id": payload.ID, "cards": len(cards), "alarms": len(alarms)}
+
+	if (len(cards) > 0 || len(alarms) > 0) && !payload.Cascade {
+		utils.WriteJSONResponse(w, http.StatusConflict, map[string]interface{}{
+			"error":  "Playlist is still used by cards or alarms, set cascade to delete it anyway",
+			"cards":  len(cards),
+			"alarms": len(alarms),
+		})
+		utils.LogMessage("INFO", "Refused to delete playlist in use", references)
+		return
+	}
+
+	for _, card := range cards {
+		if err := pocketBase.UnassignCard(r.Context(), card.ID); err != nil {
+			utils.WriteJSONResponse(w, http.StatusInternalServerError, map[string]string{"error": "Failed to unassign playlist cards"})
+			utils.LogMessage("ERROR", "Failed to unassign card in PocketBase", err.Error())
+			return
+		}
+	}
+	for _, alarm := range alarms {
+		if err := pocketBase.DeleteAlarm(r.Context(), alarm.ID); err != nil {
+			utils.WriteJSONResponse(w, http.StatusInternalServerError, map[string]string{"error": "Failed to delete playlist alarms"})
+			utils.LogMessage("ERROR", "Failed to delete alarm in PocketBase", err.Error())
+			return
+		}
+	}
+
+	if err := pocketBase.DeletePlaylist(r.Context(), payload.ID); err != nil {
+		utils.WriteJSONResponse(w, http.StatusInternalServerError, map[string]string{"error": "Failed to delete playlist"})
+		utils.LogMessage("ERROR", "Failed to delete playlist in PocketBase", err.Error())
+		return
+	}
+
+	utils.WriteJSONResponse(w, http.StatusOK, map[string]interface{}{
+		"message":         "Playlist deleted successfully",
+		"unassignedCards": len(cards),
+		"deletedAlarms":   len(alarms),
+	})
+	utils.LogMessage("INFO", "Playlist deleted successfully", references)
+}
@@ -1,41 +1,198 @@
 package handlers
 
 import (
+	"context"
+	"errors"
+	"time"
+
+	"cartophone-server/internal/alarms"
+	"cartophone-server/internal/constants"
+	"cartophone-server/internal/owntone"
 	"cartophone-server/internal/pocketbase"
+	"cartophone-server/internal/sleeptimer"
 	"cartophone-server/internal/utils"
 )
 
-// HandleReadAction handles playing the associated playlist when a card is scanned.
-func HandleReadAction(uid string, baseURL string) {
+// Scan outcome statuses
+const (
+	ScanStatusPlaying    = "playing"
+	ScanStatusCommand    = "command"
+	ScanStatusUnknown    = "unknown_card"
+	ScanStatusUnassigned = "unassigned_card"
+	ScanStatusFailed     = "failed"
+)
+
+// Scan steps, used to report where a failed scan stopped
+const (
+	ScanStepCheckCard   = "check_card"
+	ScanStepCommand     = "command"
+	ScanStepGetPlaylist = "get_playlist"
+	ScanStepClearQueue  = "clear_queue"
+	ScanStepAddToQueue  = "add_to_queue"
+	ScanStepPlay        = "play"
+)
+
+// ScanOutcome describes what happened when a card was scanned in read mode
+type ScanOutcome struct {
+	UID          string   `json:"uid"`
+	Status       string   `json:"status"`
+	Step         string   `json:"step,omitempty"`
+	CardID       string   `json:"cardId,omitempty"`
+	Action       string   `json:"action,omitempty"`
+	PlaylistID   string   `json:"playlistId,omitempty"`
+	PlaylistName string   `json:"playlistName,omitempty"`
+	URI          string   `json:"uri,omitempty"`
+	URIs         []string `json:"uris,omitempty"`
+	Resumed      bool     `json:"resumed,omitempty"`
+	Error        string   `json:"error,omitempty"`
+}
+
+// fail marks the outcome as failed at the given step
+func (o ScanOutcome) fail(step string, err error) ScanOutcome {
+	o.Status = ScanStatusFailed
+	o.Step = step
+	o.Error = err.Error()
+	return o
+}
+
+// HandleReadAction plays the playlist associated with a scanned card on Owntone,
+// or performs the card's player action for command cards. The card is the one
+// PocketBase returned for the UID, nil when it is unknown.
+func HandleReadAction(ctx context.Context, uid string, card *pocketbase.Card, pocketBase *pocketbase.Client, ownTone *owntone.Client, alarmChecker *alarms.Checker, sleepTimer *sleeptimer.Timer) ScanOutcome {
 	utils.LogMessage("INFO", "Detected card scanned", map[string]interface{}{"uid": uid})
 
-	// Check if the card exists in PocketBase
-	card, err := pocketbase.CheckCard(baseURL, uid)
-	if err != nil {
-		utils.LogMessage("ERROR", "Failed to check card in PocketBase", err.Error())
-		return
-	}
+	outcome := ScanOutcome{UID: uid}
 
 	if card == nil {
-		utils.LogMessage("INFO", "Card not found in PocketBase", map[string]interface{}{"uid": uid})
-		return
+		outcome.Status = ScanStatusUnknown
+		return outcome
+	}
+
+	outcome.CardID = card.ID
+
+	// Record the scan in the background, so that it does not delay playback.
+	// The PocketBase client timeout bounds the request.
+	go func(cardID string, at time.Time) {
+		if err := pocketBase.MarkCardSeen(context.Background(), cardID, at); err != nil {
+			utils.LogMessage("ERROR", "Failed to update card last seen time", map[string]interface{}{"uid": uid, "error": err.Error()})
+		}
+	}(card.ID, time.Now())
+
+	// Command cards control the player instead of starting a playlist
+	if constants.IsCommandAction(card.Action) {
+		outcome.Action = card.Action
+		if err := runCardCommand(ctx, card.Action, ownTone, alarmChecker, sleepTimer); err != nil {
+			return outcome.fail(ScanStepCommand, err)
+		}
+		outcome.Status = ScanStatusCommand
+		return outcome
+	}
+
+	if card.PlaylistID == "" {
+		outcome.Status = ScanStatusUnassigned
+		return outcome
 	}
 
 	// Fetch the associated playlist
-	playlist, err := pocketbase.GetPlaylist(baseURL, card.PlaylistID)
+	outcome.PlaylistID = card.PlaylistID
+	playlist, err := pocketBase.GetPlaylist(ctx, card.PlaylistID)
 	if err != nil {
-		utils.LogMessage("ERROR", "Failed to fetch playlist for card", map[string]interface{}{
-			"uid":        uid,
-			"playlistId": card.PlaylistID,
-			"error":      err.Error(),
+		return outcome.fail(ScanStepGetPlaylist, err)
+	}
+	outcome.PlaylistName = playlist.Name
+	outcome.URI = playlist.URI
+	outcome.URIs = playlist.QueueURIs()
+
+	// Play on the outputs chosen for the card, or else for the playlist. The
+	// playlist still plays on the selected outputs when those are unavailable.
+	outputs := card.Outputs
+	if len(outputs) == 0 {
+		outputs = playlist.Outputs
+	}
+	if len(outputs) > 0 {
+		if err := ownTone.SelectOutputs(ctx, outputs); err != nil {
+			utils.LogMessage("ERROR", "Failed to select outputs for card", map[string]interface{}{
+				"uid":     uid,
+				"outputs": outputs,
+				"error":   err.Error(),
+			})
+		}
+	}
+
+	// Replace the Owntone queue with the playlist and start playback
+	if err := ownTone.ClearQueue(ctx); err != nil {
+		return outcome.fail(ScanStepClearQueue, err)
+	}
+	if err := ownTone.AddToQueue(ctx, outcome.URIs); err != nil {
+		return outcome.fail(ScanStepAddToQueue, err)
+	}
+
+	// Resume where the card was last interrupted, falling back to the start track of the playlist
+	resume := card.QueuePosition > 0 || card.ProgressMs > 0
+	if resume && pastQueueEnd(ctx, card.QueuePosition, ownTone) {
+		utils.LogMessage("INFO", "Saved position is past the end of the playlist, starting from the beginning", map[string]interface{}{
+			"uid":           uid,
+			"queuePosition": card.QueuePosition,
 		})
+		resume = false
+	}
+	if resume {
+		if err := ownTone.PlayQueue(ctx, card.QueuePosition, card.ProgressMs, playlist.Shuffle, playlist.Repeat); err != nil {
+			utils.LogMessage("ERROR", "Failed to resume playlist, starting from the beginning", map[string]interface{}{
+				"uid":   uid,
+				"error": err.Error(),
+			})
+		} else {
+			outcome.Status = ScanStatusPlaying
+			outcome.Resumed = true
+			return outcome
+		}
+	}
+
+	if err := ownTone.PlayQueue(ctx, playlist.StartPosition, playlist.StartOffsetMs, playlist.Shuffle, playlist.Repeat); err != nil {
+		return outcome.fail(ScanStepPlay, err)
+	}
+
+	outcome.Status = ScanStatusPlaying
+	return outcome
+}
+
+// saveCardPosition stores the current Owntone position on the card so that
+// its playlist can be resumed later. The queue is the Owntone queue generation
+// the card started playing with, nothing is saved once the queue changed.
+func saveCardPosition(ctx context.Context, cardID string, queue uint32, pocketBase *pocketbase.Client, ownTone *owntone.Client) {
+	position, err := ownTone.GetPlaybackPosition(ctx)
+	if errors.Is(err, owntone.ErrNoCurrentItem) {
+		// The playlist played to the end, start it over next time
+		position = &owntone.PlaybackPosition{}
+	} else if err != nil {
+		utils.LogMessage("ERROR", "Failed to read playback position", map[string]interface{}{"cardId": cardID, "error": err.Error()})
+		return
+	}
+
+	// Checked after reading the position, which belongs to another playlist
+	// if the queue was replaced in the meantime
+	if ownTone.QueueGeneration() != queue {
+		utils.LogMessage("INFO", "Queue replaced, not saving playback position", map[string]interface{}{"cardId": cardID})
+		return
+	}
+
+	// Saved in the order the playlist was queued, which shuffle does not change
+	if err := pocketBase.SaveCardPosition(ctx, cardID, position.AddedPosition, position.ProgressMs); err != nil {
+		utils.LogMessage("ERROR", "Failed to save playback position", map[string]interface{}{"cardId": cardID, "error": err.Error()})
 		return
 	}
 
-	// Simulate playing the playlist
-	utils.LogMessage("ACTION", "Playing playlist", map[string]interface{}{
-		"playlistName": playlist.Name,
-		"uri":          playlist.URI,
-		"uid":          uid,
-	})
-}
\ No newline at end of file
+	utils.LogMessage("INFO", "Saved playback position", map[string]interface{}{"cardId": cardID, "position": position})
+}
+
+// pastQueueEnd reports whether a saved queue position is beyond the end of
+// the queue, such as when the playlist got shorter since it was saved
+func pastQueueEnd(ctx context.Context, position int, ownTone *owntone.Client) bool {
+	queue, err := ownTone.FetchQueue(ctx)
+	if err != nil {
+		// Resuming fails and falls back to the start if it really is
+		return false
+	}
+	return position >= len(queue)
+}
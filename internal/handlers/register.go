@@ -5,18 +5,16 @@ import (
 	"net/http"
 	"time"
 
+	"cartophone-server/internal/nfc"
 	"cartophone-server/internal/pocketbase"
 )
 
 // RegisterHandler adds a new card to the PocketBase database
-func RegisterHandler(cardDetectedChan <-chan string, baseURL string, w http.ResponseWriter, r *http.Request) {
+func RegisterHandler(cardDetectedChan <-chan nfc.Event, pocketBase *pocketbase.Client, w http.ResponseWriter, r *http.Request) {
 	fmt.Println("Register mode activated. Waiting for a card...")
 
 	// Wait for a card for 10 seconds
-	registerTimeout := time.After(10 * time.Second)
-
-	select {
-	case uid := <-cardDetectedChan:
+	if uid, detected := waitForPlacedCard(cardDetectedChan, 10*time.Second); detected {
 		// Card detected within timeout
 		fmt.Printf("Registering card %s\n", uid)
 
@@ -25,7 +23,7 @@ func RegisterHandler(cardDetectedChan <-chan string, baseURL string, w http.Resp
 			PlaylistID: "", // Leave PlaylistID empty during registration
 		}
 
-		err := pocketbase.AddCard(baseURL, card)
+		err := pocketBase.AddCard(r.Context(), card)
 		if err != nil {
 			fmt.Printf("Error registering card: %v\n", err)
 			http.Error(w, "Failed to register card", http.StatusInternalServerError)
@@ -33,7 +31,7 @@ func RegisterHandler(cardDetectedChan <-chan string, baseURL string, w http.Resp
 		}
 
 		fmt.Fprintf(w, "Card %s registered successfully!\n", uid)
-	case <-registerTimeout:
+	} else {
 		// No card detected within timeout
 		fmt.Println("No card detected")
 		fmt.Fprintf(w, "No card detected\n")
@@ -0,0 +1,169 @@
+package handlers
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+
+	"cartophone-server/internal/owntone"
+	"cartophone-server/internal/utils"
+)
+
+// runPlayerCommand answers a POST request by running a player command
+// without parameters. name identifies the handler in the logs.
+func runPlayerCommand(name, message string, command func(ctx context.Context) error, w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		utils.LogMessage("ERROR", "Invalid request method for "+name, map[string]string{"method": r.Method})
+		utils.WriteJSONResponse(w, http.StatusMethodNotAllowed, map[string]string{"error": "Invalid request method"})
+		return
+	}
+
+	if err := command(r.Context()); err != nil {
+		utils.LogMessage("ERROR", "Player command failed in "+name, map[string]string{"error": err.Error()})
+		utils.WriteJSONResponse(w, http.StatusInternalServerError, map[string]string{"error": err.Error()})
+		return
+	}
+
+	utils.LogMessage("INFO", message, nil)
+	utils.WriteJSONResponse(w, http.StatusOK, map[string]string{"message": message})
+}
+
+// NextHandler skips to the next track
+func NextHandler(ownTone *owntone.Client, w http.ResponseWriter, r *http.Request) {
+	runPlayerCommand("NextHandler", "Skipped to next track", ownTone.Next, w, r)
+}
+
+// PreviousHandler goes back to the previous track
+func PreviousHandler(ownTone *owntone.Client, w http.ResponseWriter, r *http.Request) {
+	runPlayerCommand("PreviousHandler", "Went back to previous track", ownTone.Previous, w, r)
+}
+
+// StopHandler stops playback
+func StopHandler(ownTone *owntone.Client, w http.ResponseWriter, r *http.Request) {
+	runPlayerCommand("StopHandler", "Playback stopped", ownTone.Stop, w, r)
+}
+
+// ToggleHandler toggles between play and pause
+func ToggleHandler(ownTone *owntone.Client, w http.ResponseWriter, r *http.Request) {
+	runPlayerCommand("ToggleHandler", "Playback toggled", ownTone.Toggle, w, r)
+}
+
+// SeekHandler seeks to a position in the current track, or by an offset from the current position
+func SeekHandler(ownTone *owntone.Client, w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		utils.LogMessage("ERROR", "Invalid request method for SeekHandler", map[string]string{"method": r.Method})
+		utils.WriteJSONResponse(w, http.StatusMethodNotAllowed, map[string]string{"error": "Invalid request method"})
+		return
+	}
+
+	var payload struct {
+		PositionMs *int `json:"positionMs"` // Absolute position in the track
+		OffsetMs   *int `json:"offsetMs"`   // Relative to the current position, may be negative
+	}
+	if err := json.NewDecoder(r.Body).Decode(&payload); err != nil {
+		utils.LogMessage("ERROR", "Invalid request payload for SeekHandler", nil)
+		utils.WriteJSONResponse(w, http.StatusBadRequest, map[string]string{"error": "Invalid request body"})
+		return
+	}
+
+	var err error
+	switch {
+	case payload.PositionMs != nil && payload.OffsetMs == nil:
+		if *payload.PositionMs < 0 {
+			utils.WriteJSONResponse(w, http.StatusBadRequest, map[string]string{"error": "positionMs cannot be negative"})
+			return
+		}
+		err = ownTone.Seek(r.Context(), *payload.PositionMs)
+	case payload.OffsetMs != nil && payload.PositionMs == nil:
+		err = ownTone.SeekBy(r.Context(), *payload.OffsetMs)
+	default:
+		utils.LogMessage("ERROR", "SeekHandler needs either positionMs or offsetMs", nil)
+		utils.WriteJSONResponse(w, http.StatusBadRequest, map[string]string{"error": "Either positionMs or offsetMs is required"})
+		return
+	}
+	if err != nil {
+		utils.LogMessage("ERROR", "Failed to seek", map[string]string{"error": err.Error()})
+		utils.WriteJSONResponse(w, http.StatusInternalServerError, map[string]string{"error": err.Error()})
+		return
+	}
+
+	utils.LogMessage("INFO", "Seeked successfully", payload)
+	utils.WriteJSONResponse(w, http.StatusOK, map[string]string{"message": "Seeked successfully"})
+}
+
+// ShuffleHandler enables or disables shuffle
+func ShuffleHandler(ownTone *owntone.Client, w http.ResponseWriter, r *http.Request) {
+	setPlayerMode("ShuffleHandler", "Shuffle", ownTone.SetShuffle, w, r)
+}
+
+// ConsumeHandler enables or disables consume mode
+func ConsumeHandler(ownTone *owntone.Client, w http.ResponseWriter, r *http.Request) {
+	setPlayerMode("ConsumeHandler", "Consume mode", ownTone.SetConsume, w, r)
+}
+
+// setPlayerMode answers a POST request with an {"enabled": bool} body by
+// turning a player mode on or off
+func setPlayerMode(name, mode string, set func(ctx context.Context, enabled bool) error, w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		utils.LogMessage("ERROR", "Invalid request method for "+name, map[string]string{"method": r.Method})
+		utils.WriteJSONResponse(w, http.StatusMethodNotAllowed, map[string]string{"error": "Invalid request method"})
+		return
+	}
+
+	var payload struct {
+		Enabled *bool `json:"enabled"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&payload); err != nil || payload.Enabled == nil {
+		utils.LogMessage("ERROR", "Invalid request payload for "+name, nil)
+		utils.WriteJSONResponse(w, http.StatusBadRequest, map[string]string{"error": "enabled is required"})
+		return
+	}
+
+	if err := set(r.Context(), *payload.Enabled); err != nil {
+		utils.LogMessage("ERROR", "Failed to set player mode in "+name, map[string]string{"error": err.Error()})
+		utils.WriteJSONResponse(w, http.StatusInternalServerError, map[string]string{"error": err.Error()})
+		return
+	}
+
+	state := "disabled"
+	if *payload.Enabled {
+		state = "enabled"
+	}
+	utils.LogMessage("INFO", mode+" "+state, nil)
+	utils.WriteJSONResponse(w, http.StatusOK, map[string]string{"message": mode + " " + state})
+}
+
+// RepeatHandler sets the repeat mode
+func RepeatHandler(ownTone *owntone.Client, w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		utils.LogMessage("ERROR", "Invalid request method for RepeatHandler", map[string]string{"method": r.Method})
+		utils.WriteJSONResponse(w, http.StatusMethodNotAllowed, map[string]string{"error": "Invalid request method"})
+		return
+	}
+
+	var payload struct {
+		Mode string `json:"mode"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&payload); err != nil {
+		utils.LogMessage("ERROR", "Invalid request payload for RepeatHandler", nil)
+		utils.WriteJSONResponse(w, http.StatusBadRequest, map[string]string{"error": "Invalid request body"})
+		return
+	}
+
+	switch payload.Mode {
+	case owntone.RepeatOff, owntone.RepeatAll, owntone.RepeatSingle:
+	default:
+		utils.LogMessage("ERROR", "Invalid repeat mode", payload)
+		utils.WriteJSONResponse(w, http.StatusBadRequest, map[string]string{"error": "Mode must be off, all or single"})
+		return
+	}
+
+	if err := ownTone.SetRepeat(r.Context(), payload.Mode); err != nil {
+		utils.LogMessage("ERROR", "Failed to set repeat mode", map[string]string{"error": err.Error()})
+		utils.WriteJSONResponse(w, http.StatusInternalServerError, map[string]string{"error": err.Error()})
+		return
+	}
+
+	utils.LogMessage("INFO", "Repeat mode set successfully", payload)
+	utils.WriteJSONResponse(w, http.StatusOK, map[string]string{"message": "Repeat mode set to " + payload.Mode})
+}
@@ -0,0 +1,58 @@
+package nfc
+
+import (
+	"fmt"
+	"github.com/clausecker/nfc/v2"
+	"time"
+)
+
+// DeviceReader reads NFC tags from a physical reader through libnfc
+type DeviceReader struct {
+	device          *nfc.Device
+	retriggerWindow time.Duration
+}
+
+// NewDeviceReader opens the libnfc device at devicePath.
+func NewDeviceReader(devicePath string, retriggerWindow time.Duration) (*DeviceReader, error) {
+	dev, err := nfc.Open(devicePath)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open NFC device: %v", err)
+	}
+	return &DeviceReader{device: &dev, retriggerWindow: retriggerWindow}, nil
+}
+
+// Close closes the NFC device connection
+func (r *DeviceReader) Close() {
+	if r.device != nil {
+		r.device.Close()
+	}
+}
+
+// StartRead starts scanning NFC tags
+func (r *DeviceReader) StartRead(events chan<- Event) {
+	modulations := []nfc.Modulation{
+		{Type: nfc.ISO14443a, BaudRate: nfc.Nbr106},
+	}
+
+	go func() {
+		tracker := presence{window: r.retriggerWindow}
+		for {
+			count, target, err := r.device.InitiatorPollTarget(modulations, 10, 300*time.Millisecond)
+			if err != nil {
+				fmt.Printf("Error scanning NFC tag: %v\n", err)
+				continue
+			}
+			uid := ""
+			if count > 0 {
+				isoTarget, ok := target.(*nfc.ISO14443aTarget)
+				if ok {
+					uid = fmt.Sprintf("% X", isoTarget.UID)
+				}
+			}
+			for _, event := range tracker.observe(uid, time.Now()) {
+				events <- event
+			}
+			time.Sleep(1 * time.Second)
+		}
+	}()
+}
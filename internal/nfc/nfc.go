@@ -1,52 +1,29 @@
 package nfc
 
 import (
-	"fmt"
-	"github.com/clausecker/nfc/v2"
+	"strings"
 	"time"
 )
 
-// Reader struct for NFC reader
-type Reader struct {
-	device *nfc.Device
-}
+// SimulatedPrefix selects the simulated reader when used as the device path
+const SimulatedPrefix = "sim://"
 
-// NewReader initializes the NFC reader.
-func NewReader(devicePath string) (*Reader, error) {
-	dev, err := nfc.Open(devicePath)
-	if err != nil {
-		return nil, fmt.Errorf("failed to open NFC device: %v", err)
-	}
-	return &Reader{device: &dev}, nil
+// Reader is implemented by every NFC reader backend
+type Reader interface {
+	// StartRead starts scanning NFC tags and sends an event each time a card
+	// is placed on or removed from the reader
+	StartRead(events chan<- Event)
+	// Close releases the reader
+	Close()
 }
 
-// Close closes the NFC device connection
-func (r *Reader) Close() {
-	if r.device != nil {
-		r.device.Close()
+// NewReader initializes the NFC reader for the given device path.
+// A path such as "sim://127.0.0.1:8091" selects the simulated reader,
+// any other value is passed to libnfc. A card must be missing for longer
+// than retriggerWindow before it is reported as removed and can trigger again.
+func NewReader(devicePath string, retriggerWindow time.Duration) (Reader, error) {
+	if strings.HasPrefix(devicePath, SimulatedPrefix) {
+		return NewSimulatedReader(strings.TrimPrefix(devicePath, SimulatedPrefix), retriggerWindow)
 	}
+	return NewDeviceReader(devicePath, retriggerWindow)
 }
-
-// StartRead starts scanning NFC tags
-func (r *Reader) StartRead(cardDetectedChan chan<- string) {
-	modulations := []nfc.Modulation{
-		{Type: nfc.ISO14443a, BaudRate: nfc.Nbr106},
-	}
-
-	go func() {
-		for {
-			count, target, err := r.device.InitiatorPollTarget(modulations, 10, 300*time.Millisecond)
-			if err != nil {
-				fmt.Printf("Error scanning NFC tag: %v\n", err)
-				continue
-			}
-			if count > 0 {
-				isoTarget, ok := target.(*nfc.ISO14443aTarget)
-				if ok {
-					cardDetectedChan <- fmt.Sprintf("% X", isoTarget.UID)
-				}
-			}
-			time.Sleep(1 * time.Second)
-		}
-	}()
-}
\ No newline at end of file
@@ -0,0 +1,59 @@
+package nfc
+
+import "time"
+
+// EventType tells whether a card was placed on or removed from the reader
+type EventType string
+
+const (
+	CardPlaced  EventType = "placed"
+	CardRemoved EventType = "removed"
+)
+
+// Event is emitted by a Reader when the card on it changes
+type Event struct {
+	Type EventType `json:"type"`
+	UID  string    `json:"uid"`
+}
+
+// presence tracks the card currently on the reader and turns successive
+// poll results into placed and removed events.
+//
+// A card that stays on the reader produces a single placed event. A card
+// is only reported as removed once it has been missing from the polls for
+// longer than the re-trigger window, so a poll that misses a card still on
+// the reader, or a card lifted and put straight back, does not trigger the
+// card again.
+type presence struct {
+	window   time.Duration
+	uid      string
+	lastSeen time.Time
+}
+
+// observe records the UID seen by a poll at the given time, "" meaning no
+// card, and returns the events caused by the change
+func (p *presence) observe(uid string, now time.Time) []Event {
+	switch {
+	case uid != "" && uid == p.uid:
+		p.lastSeen = now
+		return nil
+
+	case uid == "":
+		if p.uid == "" || now.Sub(p.lastSeen) < p.window {
+			return nil
+		}
+		removed := p.uid
+		p.uid = ""
+		return []Event{{Type: CardRemoved, UID: removed}}
+
+	default:
+		// A different card replaced the current one
+		var events []Event
+		if p.uid != "" {
+			events = append(events, Event{Type: CardRemoved, UID: p.uid})
+		}
+		p.uid = uid
+		p.lastSeen = now
+		return append(events, Event{Type: CardPlaced, UID: uid})
+	}
+}
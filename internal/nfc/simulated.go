@@ -0,0 +1,235 @@
+package nfc
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+
+	"cartophone-server/internal/utils"
+)
+
+// DefaultSimulatedAddr is the address the simulated reader listens on when none is given
+const DefaultSimulatedAddr = "127.0.0.1:8091"
+
+// simulatedPollInterval is how often the simulated reader polls its virtual card
+const simulatedPollInterval = 250 * time.Millisecond
+
+// SimulatedReader is a reader without hardware. Cards are placed and removed
+// with Place, Remove and Scan or through its HTTP endpoints:
+//
+//	curl -X POST -d '{"uid": "04 A1 B2 C3"}' http://127.0.0.1:8091/place
+//	curl -X POST http://127.0.0.1:8091/remove
+//	curl -X POST -d '{"uid": "04 A1 B2 C3"}' http://127.0.0.1:8091/scan
+//
+// Like the hardware reader it polls the card currently on it, so removals
+// go through the same re-trigger window.
+type SimulatedReader struct {
+	events    chan Event
+	server    *http.Server
+	done      chan struct{}
+	closeOnce sync.Once
+
+	mu      sync.Mutex
+	uid     string
+	tracker presence
+	closed  bool // Set by Close, once events is closed
+}
+
+// NewSimulatedReader starts a simulated reader listening on addr.
+func NewSimulatedReader(addr string, retriggerWindow time.Duration) (*SimulatedReader, error) {
+	if addr == "" {
+		addr = DefaultSimulatedAddr
+	}
+
+	listener, err := net.Listen("tcp", addr)
+	if err != nil {
+		return nil, fmt.Errorf("failed to listen for simulated NFC scans: %w", err)
+	}
+
+	r := &SimulatedReader{
+		events:  make(chan Event, 16),
+		done:    make(chan struct{}),
+		tracker: presence{window: retriggerWindow},
+	}
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("/place", r.placeHandler)
+	mux.HandleFunc("/remove", r.removeHandler)
+	mux.HandleFunc("/scan", r.scanHandler)
+	r.server = &http.Server{Handler: mux}
+
+	go func() {
+		if err := r.server.Serve(listener); err != nil && !errors.Is(err, http.ErrServerClosed) {
+			utils.LogMessage("ERROR", "Simulated NFC reader stopped", err.Error())
+		}
+	}()
+
+	utils.LogMessage("INFO", "Simulated NFC reader listening", map[string]string{"addr": listener.Addr().String()})
+	return r, nil
+}
+
+// Place puts a card on the reader, replacing the previous one if any
+func (r *SimulatedReader) Place(uid string) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if r.closed {
+		return
+	}
+
+	// Report the card straight away so that a brief scan is never missed
+	r.uid = uid
+	r.poll()
+}
+
+// Remove lifts the current card off the reader
+func (r *SimulatedReader) Remove() {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	r.uid = ""
+}
+
+// Scan briefly presents a card to the reader
+func (r *SimulatedReader) Scan(uid string) {
+	r.Place(uid)
+	r.Remove()
+}
+
+// Close stops polling and the HTTP endpoints, then closes the events channel
+func (r *SimulatedReader) Close() {
+	r.closeOnce.Do(func() {
+		close(r.done)
+		if err := r.server.Close(); err != nil {
+			utils.LogMessage("ERROR", "Failed to close simulated NFC reader", err.Error())
+		}
+
+		// Handlers still running see closed and no longer send events
+		r.mu.Lock()
+		r.closed = true
+		close(r.events)
+		r.mu.Unlock()
+	})
+}
+
+// isClosed reports whether Close was called
+func (r *SimulatedReader) isClosed() bool {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	return r.closed
+}
+
+// StartRead polls the simulated card and forwards its events to events
+func (r *SimulatedReader) StartRead(events chan<- Event) {
+	go func() {
+		ticker := time.NewTicker(simulatedPollInterval)
+		defer ticker.Stop()
+
+		for {
+			select {
+			case <-ticker.C:
+				r.mu.Lock()
+				r.poll()
+				r.mu.Unlock()
+			case <-r.done:
+				return
+			}
+		}
+	}()
+
+	go func() {
+		for event := range r.events {
+			events <- event
+		}
+	}()
+}
+
+// poll feeds the current simulated card to the presence tracker. The caller must hold r.mu.
+func (r *SimulatedReader) poll() {
+	if r.closed {
+		return
+	}
+	for _, event := range r.tracker.observe(r.uid, time.Now()) {
+		r.events <- event
+	}
+}
+
+// placeHandler places the card posted in the request body on the reader
+func (r *SimulatedReader) placeHandler(w http.ResponseWriter, req *http.Request) {
+	uid, ok := r.decodeSimulatedUID(w, req)
+	if !ok {
+		return
+	}
+
+	utils.LogMessage("DEBUG", "Simulated card placed", map[string]string{"uid": uid})
+	r.Place(uid)
+	utils.WriteJSONResponse(w, http.StatusOK, map[string]string{"message": "Card placed", "uid": uid})
+}
+
+// removeHandler lifts the current card off the reader
+func (r *SimulatedReader) removeHandler(w http.ResponseWriter, req *http.Request) {
+	if req.Method != http.MethodPost {
+		utils.WriteJSONResponse(w, http.StatusMethodNotAllowed, map[string]string{"error": "Invalid request method"})
+		return
+	}
+	if r.isClosed() {
+		writeReaderClosed(w)
+		return
+	}
+
+	utils.LogMessage("DEBUG", "Simulated card removed", nil)
+	r.Remove()
+	utils.WriteJSONResponse(w, http.StatusOK, map[string]string{"message": "Card removed"})
+}
+
+// scanHandler briefly presents the card posted in the request body
+func (r *SimulatedReader) scanHandler(w http.ResponseWriter, req *http.Request) {
+	uid, ok := r.decodeSimulatedUID(w, req)
+	if !ok {
+		return
+	}
+
+	utils.LogMessage("DEBUG", "Simulated card scan", map[string]string{"uid": uid})
+	r.Scan(uid)
+	utils.WriteJSONResponse(w, http.StatusOK, map[string]string{"message": "Card scanned", "uid": uid})
+}
+
+// writeReaderClosed answers requests received after the reader was closed
+func writeReaderClosed(w http.ResponseWriter) {
+	utils.WriteJSONResponse(w, http.StatusServiceUnavailable, map[string]string{"error": "Simulated reader is closed"})
+}
+
+// decodeSimulatedUID reads the UID from a simulated reader request, writing
+// an error response when it is missing or the reader is closed
+func (r *SimulatedReader) decodeSimulatedUID(w http.ResponseWriter, req *http.Request) (string, bool) {
+	if req.Method != http.MethodPost {
+		utils.WriteJSONResponse(w, http.StatusMethodNotAllowed, map[string]string{"error": "Invalid request method"})
+		return "", false
+	}
+	if r.isClosed() {
+		writeReaderClosed(w)
+		return "", false
+	}
+
+	var payload struct {
+		UID string `json:"uid"`
+	}
+	if err := json.NewDecoder(req.Body).Decode(&payload); err != nil {
+		utils.WriteJSONResponse(w, http.StatusBadRequest, map[string]string{"error": "Invalid request body"})
+		return "", false
+	}
+
+	uid := strings.TrimSpace(payload.UID)
+	if uid == "" {
+		utils.WriteJSONResponse(w, http.StatusBadRequest, map[string]string{"error": "UID is required"})
+		return "", false
+	}
+
+	return uid, true
+}
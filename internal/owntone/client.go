@@ -0,0 +1,98 @@
+package owntone
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync/atomic"
+	"time"
+)
+
+// DefaultTimeout is used when the client is created without an http.Client
+const DefaultTimeout = 10 * time.Second
+
+// Client talks to the Owntone JSON API
+type Client struct {
+	baseURL    string
+	httpClient *http.Client
+
+	queueGeneration uint32 // Changed each time this client changes the queue, see QueueGeneration
+}
+
+// NewClient creates a client for the Owntone server at baseURL. When
+// httpClient is nil, a client with DefaultTimeout is used.
+func NewClient(baseURL string, httpClient *http.Client) *Client {
+	if httpClient == nil {
+		httpClient = &http.Client{Timeout: DefaultTimeout}
+	}
+	return &Client{
+		baseURL:    strings.TrimRight(baseURL, "/"),
+		httpClient: httpClient,
+	}
+}
+
+// QueueGeneration returns a number that changes each time the queue is
+// cleared or its items are added, removed or moved through this client. Code
+// that started playing a queue compares it to the one it recorded to tell
+// whether something else replaced the queue since.
+func (c *Client) QueueGeneration() uint32 {
+	return atomic.LoadUint32(&c.queueGeneration)
+}
+
+// queueChanged records a change of the queue, see QueueGeneration. It is
+// called before the change is sent, since a failed request may still have
+// changed the queue.
+func (c *Client) queueChanged() {
+	atomic.AddUint32(&c.queueGeneration, 1)
+}
+
+// do sends a request to the Owntone API. body, when not nil, is sent as
+// JSON and out, when not nil, receives the decoded JSON response.
+func (c *Client) do(ctx context.Context, method, path string, query url.Values, body, out interface{}) error {
+	endpoint := c.baseURL + path
+	if len(query) > 0 {
+		endpoint += "?" + query.Encode()
+	}
+
+	var reqBody io.Reader
+	if body != nil {
+		data, err := json.Marshal(body)
+		if err != nil {
+			return fmt.Errorf("failed to encode request: %w", err)
+		}
+		reqBody = bytes.NewReader(data)
+	}
+
+	req, err := http.NewRequestWithContext(ctx, method, endpoint, reqBody)
+	if err != nil {
+		return fmt.Errorf("failed to create request: %w", err)
+	}
+	if body != nil {
+		req.Header.Set("Content-Type", "application/json")
+	}
+
+	resp, err := c.httpClient.Do(req)
+	if err != nil {
+		return fmt.Errorf("failed to reach Owntone: %w", err)
+	}
+	defer resp.Body.Close()
+
+	// Treat 204 No Content as a successful response
+	if resp.StatusCode != http.StatusOK && resp.StatusCode != http.StatusNoContent {
+		respBody, _ := io.ReadAll(resp.Body)
+		return fmt.Errorf("unexpected response from Owntone: %s: %s", resp.Status, strings.TrimSpace(string(respBody)))
+	}
+
+	if out != nil && resp.StatusCode != http.StatusNoContent {
+		if err := json.NewDecoder(resp.Body).Decode(out); err != nil {
+			return fmt.Errorf("failed to decode response: %w", err)
+		}
+	}
+
+	return nil
+}
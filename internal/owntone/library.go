@@ -0,0 +1,140 @@
+package owntone
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+)
+
+// Search types accepted by Search
+const (
+	SearchTracks    = "tracks"
+	SearchArtists   = "artists"
+	SearchAlbums    = "albums"
+	SearchPlaylists = "playlists"
+)
+
+// SearchTypes are all the search types, in the order results are returned
+var SearchTypes = []string{SearchTracks, SearchArtists, SearchAlbums, SearchPlaylists}
+
+// Track is a track of the Owntone library
+type Track struct {
+	ID          int    `json:"id"`
+	Title       string `json:"title"`
+	Artist      string `json:"artist"`
+	Album       string `json:"album"`
+	AlbumArtist string `json:"album_artist"`
+	TrackNumber int    `json:"track_number"`
+	DiscNumber  int    `json:"disc_number"`
+	LengthMs    int    `json:"length_ms"`
+	URI         string `json:"uri"`
+	ArtworkURL  string `json:"artwork_url"`
+}
+
+// Artist is an artist of the Owntone library
+type Artist struct {
+	ID         string `json:"id"`
+	Name       string `json:"name"`
+	AlbumCount int    `json:"album_count"`
+	TrackCount int    `json:"track_count"`
+	URI        string `json:"uri"`
+	ArtworkURL string `json:"artwork_url"`
+}
+
+// Album is an album of the Owntone library
+type Album struct {
+	ID         string `json:"id"`
+	Name       string `json:"name"`
+	Artist     string `json:"artist"`
+	ArtistID   string `json:"artist_id"`
+	TrackCount int    `json:"track_count"`
+	LengthMs   int    `json:"length_ms"`
+	URI        string `json:"uri"`
+	ArtworkURL string `json:"artwork_url"`
+}
+
+// Playlist is a playlist of the Owntone library, such as an M3U file or a
+// Spotify playlist
+type Playlist struct {
+	ID         int    `json:"id"`
+	Name       string `json:"name"`
+	Type       string `json:"type"`
+	ItemCount  int    `json:"item_count"`
+	URI        string `json:"uri"`
+	ArtworkURL string `json:"artwork_url,omitempty"`
+}
+
+// SearchResults holds the matches of a library search, by type. Types that
+// were not searched are nil.
+type SearchResults struct {
+	Tracks    *TrackResults    `json:"tracks,omitempty"`
+	Artists   *ArtistResults   `json:"artists,omitempty"`
+	Albums    *AlbumResults    `json:"albums,omitempty"`
+	Playlists *PlaylistResults `json:"playlists,omitempty"`
+}
+
+// TrackResults are tracks matching a search. Total counts all the matches,
+// even those past the limit, and likewise for the other result types.
+type TrackResults struct {
+	Items []Track `json:"items"`
+	Total int     `json:"total"`
+}
+
+// ArtistResults are artists matching a search
+type ArtistResults struct {
+	Items []Artist `json:"items"`
+	Total int      `json:"total"`
+}
+
+// AlbumResults are albums matching a search
+type AlbumResults struct {
+	Items []Album `json:"items"`
+	Total int     `json:"total"`
+}
+
+// PlaylistResults are playlists matching a search
+type PlaylistResults struct {
+	Items []Playlist `json:"items"`
+	Total int        `json:"total"`
+}
+
+// Search searches the Owntone library for the given types, returning at most
+// limit results per type. A limit of 0 uses the Owntone default.
+func (c *Client) Search(ctx context.Context, query string, types []string, limit int) (*SearchResults, error) {
+	params := url.Values{
+		"query": {query},
+		"type":  {strings.Join(types, ",")},
+	}
+	if limit > 0 {
+		params.Set("limit", strconv.Itoa(limit))
+	}
+
+	var results SearchResults
+	if err := c.do(ctx, http.MethodGet, "/api/search", params, nil, &results); err != nil {
+		return nil, fmt.Errorf("failed to search library: %w", err)
+	}
+	return &results, nil
+}
+
+// ArtistAlbums fetches the albums of an artist
+func (c *Client) ArtistAlbums(ctx context.Context, artistID string) ([]Album, error) {
+	var page AlbumResults
+	path := fmt.Sprintf("/api/library/artists/%s/albums", url.PathEscape(artistID))
+	if err := c.do(ctx, http.MethodGet, path, nil, nil, &page); err != nil {
+		return nil, fmt.Errorf("failed to fetch albums of artist %s: %w", artistID, err)
+	}
+	return page.Items, nil
+}
+
+// AlbumTracks fetches the tracks of an album
+func (c *Client) AlbumTracks(ctx context.Context, albumID string) ([]Track, error) {
+	var page TrackResults
+	path := fmt.Sprintf("/api/library/albums/%s/tracks", url.PathEscape(albumID))
+	if err := c.do(ctx, http.MethodGet, path, nil, nil, &page); err != nil {
+		return nil, fmt.Errorf("failed to fetch tracks of album %s: %w", albumID, err)
+	}
+	return page.Items, nil
+}
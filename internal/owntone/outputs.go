@@ -0,0 +1,65 @@
+package owntone
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strconv"
+)
+
+// Output is a speaker Owntone can play on, such as an AirPlay or Chromecast
+// device or the local sound card
+type Output struct {
+	ID           string `json:"id"`
+	Name         string `json:"name"`
+	Type         string `json:"type"`
+	Selected     bool   `json:"selected"`
+	HasPassword  bool   `json:"has_password"`
+	RequiresAuth bool   `json:"requires_auth"`
+	NeedsAuthKey bool   `json:"needs_auth_key"`
+	Volume       int    `json:"volume"`
+}
+
+// OutputChange holds the settings to change on an output, nil fields are left as they are
+type OutputChange struct {
+	Selected *bool `json:"selected,omitempty"`
+	Volume   *int  `json:"volume,omitempty"`
+}
+
+// ListOutputs fetches the outputs known to Owntone
+func (c *Client) ListOutputs(ctx context.Context) ([]Output, error) {
+	var response struct {
+		Outputs []Output `json:"outputs"`
+	}
+	if err := c.do(ctx, http.MethodGet, "/api/outputs", nil, nil, &response); err != nil {
+		return nil, fmt.Errorf("failed to fetch outputs: %w", err)
+	}
+	return response.Outputs, nil
+}
+
+// UpdateOutput enables, disables or sets the volume of an output
+func (c *Client) UpdateOutput(ctx context.Context, id string, change OutputChange) error {
+	if err := c.do(ctx, http.MethodPut, "/api/outputs/"+url.PathEscape(id), nil, change, nil); err != nil {
+		return fmt.Errorf("failed to update output %s: %w", id, err)
+	}
+	return nil
+}
+
+// SelectOutputs enables the given outputs and disables all the others
+func (c *Client) SelectOutputs(ctx context.Context, ids []string) error {
+	body := map[string][]string{"outputs": ids}
+	if err := c.do(ctx, http.MethodPut, "/api/outputs/set", nil, body, nil); err != nil {
+		return fmt.Errorf("failed to select outputs: %w", err)
+	}
+	return nil
+}
+
+// SetOutputVolume sets the volume (0-100) of a single output
+func (c *Client) SetOutputVolume(ctx context.Context, id string, volume int) error {
+	query := url.Values{
+		"volume":    {strconv.Itoa(volume)},
+		"output_id": {id},
+	}
+	return c.sendPlayerCommand(ctx, "volume", "/api/player/volume", query)
+}
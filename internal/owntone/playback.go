@@ -1,52 +1,194 @@
 package owntone
 
 import (
+	"context"
 	"fmt"
 	"net/http"
+	"net/url"
+	"strconv"
+	"time"
 )
 
-// Play sends a request to the Owntone API to start playback
-func Play(baseURL string) error {
-	url := fmt.Sprintf("%s/api/player/play", baseURL)
+// fadeStepInterval is the time between two volume changes of a fade
+const fadeStepInterval = time.Second
 
-	req, err := http.NewRequest(http.MethodPut, url, nil) // Change to PUT method
-	if err != nil {
-		return fmt.Errorf("failed to create play command request: %w", err)
+// Repeat modes of the player
+const (
+	RepeatOff    = "off"
+	RepeatAll    = "all"
+	RepeatSingle = "single"
+)
+
+// Shuffle modes of a queue started with PlayQueue
+const (
+	ShuffleOn  = "on"
+	ShuffleOff = "off"
+)
+
+// sendPlayerCommand sends a PUT request to an Owntone player endpoint.
+// name is only used in error messages.
+func (c *Client) sendPlayerCommand(ctx context.Context, name, path string, query url.Values) error {
+	if err := c.do(ctx, http.MethodPut, path, query, nil, nil); err != nil {
+		return fmt.Errorf("failed to send %s command: %w", name, err)
 	}
+	return nil
+}
+
+// Play starts playback
+func (c *Client) Play(ctx context.Context) error {
+	return c.sendPlayerCommand(ctx, "play", "/api/player/play", nil)
+}
+
+// Pause pauses playback
+func (c *Client) Pause(ctx context.Context) error {
+	return c.sendPlayerCommand(ctx, "pause", "/api/player/pause", nil)
+}
+
+// Toggle toggles between play and pause
+func (c *Client) Toggle(ctx context.Context) error {
+	return c.sendPlayerCommand(ctx, "toggle", "/api/player/toggle", nil)
+}
 
-	resp, err := http.DefaultClient.Do(req)
-	if err != nil {
-		return fmt.Errorf("failed to send play command: %w", err)
+// Stop stops playback
+func (c *Client) Stop(ctx context.Context) error {
+	return c.sendPlayerCommand(ctx, "stop", "/api/player/stop", nil)
+}
+
+// Next skips to the next track
+func (c *Client) Next(ctx context.Context) error {
+	return c.sendPlayerCommand(ctx, "next", "/api/player/next", nil)
+}
+
+// Previous goes back to the previous track
+func (c *Client) Previous(ctx context.Context) error {
+	return c.sendPlayerCommand(ctx, "previous", "/api/player/previous", nil)
+}
+
+// SetVolume sets the master volume (0-100)
+func (c *Client) SetVolume(ctx context.Context, volume int) error {
+	return c.sendPlayerCommand(ctx, "volume", "/api/player/volume", url.Values{"volume": {strconv.Itoa(volume)}})
+}
+
+// FadeVolume ramps the master volume from one level to another over duration,
+// changing it once per fadeStepInterval. It stops early, returning the
+// context error, when ctx is cancelled.
+func (c *Client) FadeVolume(ctx context.Context, from, to int, duration time.Duration) error {
+	steps := int(duration / fadeStepInterval)
+	if steps < 1 {
+		return c.SetVolume(ctx, to)
 	}
-	defer resp.Body.Close()
 
-	// Treat 204 No Content as a successful response
-	if resp.StatusCode != http.StatusOK && resp.StatusCode != http.StatusNoContent {
-		return fmt.Errorf("unexpected response: %s", resp.Status)
+	if err := c.SetVolume(ctx, from); err != nil {
+		return err
 	}
 
+	ticker := time.NewTicker(fadeStepInterval)
+	defer ticker.Stop()
+
+	for step := 1; step <= steps; step++ {
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-ticker.C:
+		}
+
+		if err := c.SetVolume(ctx, from+(to-from)*step/steps); err != nil {
+			return err
+		}
+	}
 	return nil
 }
 
-// Pause sends a request to the Owntone API to pause playback
-func Pause(baseURL string) error {
-	url := fmt.Sprintf("%s/api/player/pause", baseURL)
+// ChangeVolume changes the master volume by step (may be negative)
+func (c *Client) ChangeVolume(ctx context.Context, step int) error {
+	return c.sendPlayerCommand(ctx, "volume", "/api/player/volume", url.Values{"step": {strconv.Itoa(step)}})
+}
+
+// SetShuffle enables or disables shuffle
+func (c *Client) SetShuffle(ctx context.Context, enabled bool) error {
+	return c.sendPlayerCommand(ctx, "shuffle", "/api/player/shuffle", url.Values{"state": {strconv.FormatBool(enabled)}})
+}
+
+// PlayAtPosition starts playback at a queue position
+func (c *Client) PlayAtPosition(ctx context.Context, position int) error {
+	return c.sendPlayerCommand(ctx, "play", "/api/player/play", url.Values{"position": {strconv.Itoa(position)}})
+}
+
+// Seek seeks to a position in the current track
+func (c *Client) Seek(ctx context.Context, positionMs int) error {
+	return c.sendPlayerCommand(ctx, "seek", "/api/player/seek", url.Values{"position_ms": {strconv.Itoa(positionMs)}})
+}
+
+// SeekBy seeks forward, or backward when offsetMs is negative, in the current track
+func (c *Client) SeekBy(ctx context.Context, offsetMs int) error {
+	return c.sendPlayerCommand(ctx, "seek", "/api/player/seek", url.Values{"seek_ms": {strconv.Itoa(offsetMs)}})
+}
+
+// SetRepeat sets the repeat mode, one of RepeatOff, RepeatAll or RepeatSingle
+func (c *Client) SetRepeat(ctx context.Context, mode string) error {
+	return c.sendPlayerCommand(ctx, "repeat", "/api/player/repeat", url.Values{"state": {mode}})
+}
 
-	req, err := http.NewRequest(http.MethodPut, url, nil) // Change to PUT method
-	if err != nil {
-		return fmt.Errorf("failed to create pause command request: %w", err)
+// SetConsume enables or disables consume mode, which removes tracks from the queue once played
+func (c *Client) SetConsume(ctx context.Context, enabled bool) error {
+	return c.sendPlayerCommand(ctx, "consume", "/api/player/consume", url.Values{"state": {strconv.FormatBool(enabled)}})
+}
+
+// PlayFrom starts playback at a queue position and offset in that track.
+// Zero for both plays the queue from its start.
+func (c *Client) PlayFrom(ctx context.Context, position int, offsetMs int) error {
+	if position == 0 && offsetMs == 0 {
+		return c.Play(ctx)
+	}
+	if err := c.PlayAtPosition(ctx, position); err != nil {
+		return err
 	}
+	if offsetMs > 0 {
+		return c.Seek(ctx, offsetMs)
+	}
+	return nil
+}
 
-	resp, err := http.DefaultClient.Do(req)
-	if err != nil {
-		return fmt.Errorf("failed to send pause command: %w", err)
+// PlayQueue starts playback at a queue position, in the order the items were
+// added, and offset in that track. shuffle is ShuffleOn or ShuffleOff and
+// repeat one of the repeat modes, empty keeps the current mode.
+func (c *Client) PlayQueue(ctx context.Context, position, offsetMs int, shuffle, repeat string) error {
+	if repeat != "" {
+		if err := c.SetRepeat(ctx, repeat); err != nil {
+			return err
+		}
 	}
-	defer resp.Body.Close()
 
-	// Treat 204 No Content as a successful response
-	if resp.StatusCode != http.StatusOK && resp.StatusCode != http.StatusNoContent {
-		return fmt.Errorf("unexpected response: %s", resp.Status)
+	shuffled := shuffle == ShuffleOn
+	if position == 0 && offsetMs == 0 {
+		// Any track may start a shuffled queue
+		if shuffle != "" {
+			if err := c.SetShuffle(ctx, shuffled); err != nil {
+				return err
+			}
+		}
+		return c.Play(ctx)
 	}
 
+	// Positions follow the shuffled order while shuffle is on, so the track
+	// starts with shuffle off and the rest of the queue is shuffled afterwards
+	if shuffle == "" {
+		status, err := c.GetPlayerStatus(ctx)
+		if err != nil {
+			return err
+		}
+		shuffled = status.Shuffle
+	}
+	if shuffled || shuffle == ShuffleOff {
+		if err := c.SetShuffle(ctx, false); err != nil {
+			return err
+		}
+	}
+	if err := c.PlayFrom(ctx, position, offsetMs); err != nil {
+		return err
+	}
+	if shuffled {
+		return c.SetShuffle(ctx, true)
+	}
 	return nil
-}
\ No newline at end of file
+}
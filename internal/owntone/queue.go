@@ -1,11 +1,13 @@
 package owntone
 
 import (
-	"bytes"
-	"encoding/json"
+	"context"
+	"errors"
 	"fmt"
-	"io/ioutil"
 	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
 
 	"cartophone-server/internal/utils"
 )
@@ -23,87 +25,97 @@ type QueueItem struct {
 }
 
 // FetchQueue fetches the current queue from Owntone
-func FetchQueue(baseURL string) ([]map[string]interface{}, error) {
-	url := fmt.Sprintf("%s/api/player/queue", baseURL)
-	utils.LogMessage("INFO", "Fetching Owntone queue", map[string]string{"url": url})
-
-	resp, err := http.Get(url)
-	if err != nil {
-		utils.LogMessage("ERROR", "Failed to fetch queue", map[string]string{"error": err.Error()})
-		return nil, fmt.Errorf("failed to fetch queue: %w", err)
-	}
-	defer resp.Body.Close()
-
-	body, _ := ioutil.ReadAll(resp.Body) // Read for debugging purposes
-	utils.LogMessage("DEBUG", "Response body received", map[string]string{"body": string(body)})
-
-	if resp.StatusCode != http.StatusOK {
-		return nil, fmt.Errorf("unexpected response: %s", resp.Status)
-	}
+func (c *Client) FetchQueue(ctx context.Context) ([]QueueItem, error) {
+	utils.LogMessage("INFO", "Fetching Owntone queue", nil)
 
 	var result struct {
-		Items []map[string]interface{} `json:"items"`
+		Items []QueueItem `json:"items"`
 	}
-	if err := json.Unmarshal(body, &result); err != nil {
-		return nil, fmt.Errorf("failed to decode response: %w", err)
+	if err := c.do(ctx, http.MethodGet, "/api/queue", nil, nil, &result); err != nil {
+		utils.LogMessage("ERROR", "Failed to fetch queue", map[string]string{"error": err.Error()})
+		return nil, fmt.Errorf("failed to fetch queue: %w", err)
 	}
 
 	return result.Items, nil
 }
 
 // ClearQueue clears the Owntone queue
-func ClearQueue(baseURL string) error {
-	url := fmt.Sprintf("%s/api/player/queue/clear", baseURL)
-	utils.LogMessage("INFO", "Clearing Owntone queue", map[string]string{"url": url})
-
-	req, err := http.NewRequest(http.MethodPut, url, nil)
-	if err != nil {
-		utils.LogMessage("ERROR", "Failed to create PUT request to clear queue", map[string]string{"error": err.Error()})
-		return fmt.Errorf("failed to create clear queue request: %w", err)
-	}
+func (c *Client) ClearQueue(ctx context.Context) error {
+	utils.LogMessage("INFO", "Clearing Owntone queue", nil)
 
-	resp, err := http.DefaultClient.Do(req)
-	if err != nil {
+	c.queueChanged()
+	if err := c.do(ctx, http.MethodPut, "/api/queue/clear", nil, nil, nil); err != nil {
 		utils.LogMessage("ERROR", "Failed to clear queue", map[string]string{"error": err.Error()})
 		return fmt.Errorf("failed to clear queue: %w", err)
 	}
-	defer resp.Body.Close()
-
-	// Treat 204 as a successful response
-	if resp.StatusCode != http.StatusOK && resp.StatusCode != http.StatusNoContent {
-		body, _ := ioutil.ReadAll(resp.Body)
-		utils.LogMessage("ERROR", "Unexpected response status", map[string]string{"status": resp.Status, "body": string(body)})
-		return fmt.Errorf("unexpected response: %s", string(body))
-	}
 
 	utils.LogMessage("INFO", "Owntone queue cleared successfully", nil)
 	return nil
 }
 
 // AddToQueue adds items to the Owntone queue
-func AddToQueue(baseURL string, uris []string) error {
-	url := fmt.Sprintf("%s/api/player/queue/items/add", baseURL)
-	utils.LogMessage("INFO", "Adding items to Owntone queue", map[string]interface{}{"uris": uris})
+func (c *Client) AddToQueue(ctx context.Context, uris []string) error {
+	return c.addToQueue(ctx, uris, nil)
+}
+
+// InsertIntoQueue adds items to the Owntone queue at the given position
+func (c *Client) InsertIntoQueue(ctx context.Context, uris []string, position int) error {
+	return c.addToQueue(ctx, uris, url.Values{"position": {strconv.Itoa(position)}})
+}
 
-	payload := map[string]interface{}{
-		"uris": uris,
+// PlayNext adds items to the Owntone queue right after the current track,
+// or at the start of the queue when nothing is playing
+func (c *Client) PlayNext(ctx context.Context, uris []string) error {
+	position := 0
+	current, err := c.GetPlaybackPosition(ctx)
+	switch {
+	case err == nil:
+		position = current.QueuePosition + 1
+	case !errors.Is(err, ErrNoCurrentItem):
+		return err
 	}
-	data, _ := json.Marshal(payload)
+	return c.InsertIntoQueue(ctx, uris, position)
+}
 
-	resp, err := http.Post(url, "application/json", bytes.NewBuffer(data))
-	if err != nil {
+// addToQueue adds items to the Owntone queue with extra query parameters
+func (c *Client) addToQueue(ctx context.Context, uris []string, extra url.Values) error {
+	utils.LogMessage("INFO", "Adding items to Owntone queue", map[string]interface{}{"uris": uris, "options": extra})
+
+	// Owntone expects the URIs as a comma separated query parameter
+	query := url.Values{"uris": {strings.Join(uris, ",")}}
+	for key, values := range extra {
+		query[key] = values
+	}
+	c.queueChanged()
+	if err := c.do(ctx, http.MethodPost, "/api/queue/items/add", query, nil, nil); err != nil {
 		utils.LogMessage("ERROR", "Failed to add track to queue", map[string]string{"error": err.Error()})
 		return fmt.Errorf("failed to add items to queue: %w", err)
 	}
-	defer resp.Body.Close()
 
-	body, _ := ioutil.ReadAll(resp.Body) // Read for debugging purposes
-	utils.LogMessage("DEBUG", "Response body received", map[string]string{"body": string(body)})
+	utils.LogMessage("INFO", "Owntone queue items added successfully", map[string]interface{}{"uris": uris})
+	return nil
+}
 
-	if resp.StatusCode != http.StatusOK && resp.StatusCode != http.StatusNoContent {
-		return fmt.Errorf("unexpected response: %s", resp.Status)
+// RemoveQueueItem removes an item from the Owntone queue by ID
+func (c *Client) RemoveQueueItem(ctx context.Context, id int) error {
+	c.queueChanged()
+	if err := c.do(ctx, http.MethodDelete, fmt.Sprintf("/api/queue/items/%d", id), nil, nil, nil); err != nil {
+		return fmt.Errorf("failed to remove queue item %d: %w", id, err)
 	}
+	return nil
+}
 
-	utils.LogMessage("INFO", "Owntone queue items added successfully", map[string]interface{}{"uris": uris})
+// MoveQueueItem moves an item of the Owntone queue to a new position
+func (c *Client) MoveQueueItem(ctx context.Context, id, position int) error {
+	query := url.Values{"new_position": {strconv.Itoa(position)}}
+	c.queueChanged()
+	if err := c.do(ctx, http.MethodPut, fmt.Sprintf("/api/queue/items/%d", id), query, nil, nil); err != nil {
+		return fmt.Errorf("failed to move queue item %d: %w", id, err)
+	}
 	return nil
-}
\ No newline at end of file
+}
+
+// PlayQueueItem starts playback at an item of the queue
+func (c *Client) PlayQueueItem(ctx context.Context, id int) error {
+	return c.sendPlayerCommand(ctx, "play", "/api/player/play", url.Values{"item_id": {strconv.Itoa(id)}})
+}
@@ -1,31 +1,81 @@
 package owntone
 
 import (
-	"encoding/json"
+	"context"
+	"errors"
 	"fmt"
-	"io"
 	"net/http"
 )
 
-// GetPlayerStatus fetches the status of the OwnTone player
-func GetPlayerStatus(baseURL string) (map[string]interface{}, error) {
-	url := fmt.Sprintf("%s/api/player", baseURL)
+// Player states reported by Owntone
+const (
+	StatePlay  = "play"
+	StatePause = "pause"
+	StateStop  = "stop"
+)
 
-	resp, err := http.Get(url)
-	if err != nil {
+// PlayerStatus is the state of the Owntone player
+type PlayerStatus struct {
+	State          string `json:"state"`
+	Repeat         string `json:"repeat"`
+	Consume        bool   `json:"consume"`
+	Shuffle        bool   `json:"shuffle"`
+	Volume         int    `json:"volume"`
+	ItemID         int    `json:"item_id"`
+	ItemLengthMs   int    `json:"item_length_ms"`
+	ItemProgressMs int    `json:"item_progress_ms"`
+}
+
+// GetPlayerStatus fetches the status of the OwnTone player
+func (c *Client) GetPlayerStatus(ctx context.Context) (*PlayerStatus, error) {
+	var status PlayerStatus
+	if err := c.do(ctx, http.MethodGet, "/api/player", nil, nil, &status); err != nil {
 		return nil, fmt.Errorf("failed to fetch player status: %w", err)
 	}
-	defer resp.Body.Close()
+	return &status, nil
+}
+
+// ErrNoCurrentItem is returned when the player has no current track, such as
+// when the queue is empty
+var ErrNoCurrentItem = errors.New("player status has no current item")
+
+// PlaybackPosition is the position of the player in the queue
+type PlaybackPosition struct {
+	QueuePosition int `json:"queuePosition"`
+	ProgressMs    int `json:"progressMs"`
 
-	if resp.StatusCode != http.StatusOK {
-		body, _ := io.ReadAll(resp.Body)
-		return nil, fmt.Errorf("unexpected response from OwnTone: %s", string(body))
+	// Position in the order the items were added, which shuffle does not change
+	AddedPosition int `json:"addedPosition"`
+}
+
+// GetPlaybackPosition returns the queue position and progress of the current track
+func (c *Client) GetPlaybackPosition(ctx context.Context) (*PlaybackPosition, error) {
+	status, err := c.GetPlayerStatus(ctx)
+	if err != nil {
+		return nil, err
+	}
+	if status.ItemID == 0 {
+		return nil, ErrNoCurrentItem
 	}
 
-	var playerStatus map[string]interface{}
-	if err := json.NewDecoder(resp.Body).Decode(&playerStatus); err != nil {
-		return nil, fmt.Errorf("failed to decode player status response: %w", err)
+	queue, err := c.FetchQueue(ctx)
+	if err != nil {
+		return nil, err
 	}
 
-	return playerStatus, nil
-}
\ No newline at end of file
+	// Item IDs grow as items are added to the queue
+	var position *PlaybackPosition
+	added := 0
+	for _, item := range queue {
+		if item.ID == status.ItemID {
+			position = &PlaybackPosition{QueuePosition: item.Position, ProgressMs: status.ItemProgressMs}
+		} else if item.ID < status.ItemID {
+			added++
+		}
+	}
+	if position == nil {
+		return nil, fmt.Errorf("current item %d is not in the queue", status.ItemID)
+	}
+	position.AddedPosition = added
+	return position, nil
+}
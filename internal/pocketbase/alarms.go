@@ -1,254 +1,217 @@
 package pocketbase
 
 import (
-	"bytes"
+	"context"
 	"encoding/json"
 	"fmt"
-	"io/ioutil"
 	"net/http"
-	"net/url"
+	"time"
 
 	"cartophone-server/internal/utils"
 )
 
+// DateLayout is the layout of the Date field of an alarm
+const DateLayout = "2006-01-02"
+
+// Weekday masks for the Weekdays field of an alarm. Bit n is set when the
+// alarm rings on time.Weekday(n), a mask of 0 rings every day.
+const (
+	EveryDay   = 0
+	SchoolDays = 1<<time.Monday | 1<<time.Tuesday | 1<<time.Wednesday | 1<<time.Thursday | 1<<time.Friday
+	Weekend    = 1<<time.Saturday | 1<<time.Sunday
+	AllDays    = SchoolDays | Weekend
+)
+
 // Alarm represents an alarm object in PocketBase
 type Alarm struct {
 	ID         string `json:"id"`
 	Hour       string `json:"hour"`
 	Activated  bool   `json:"activated"`
 	PlaylistID string `json:"playlistId"`
+	Weekdays   int    `json:"weekdays"` // Weekday mask, 0 rings every day
+	OneShot    bool   `json:"oneShot"`  // Deactivate the alarm once it has rung
+	Date       string `json:"date"`     // Only ring on this date (YYYY-MM-DD) when set
+
+	// IANA timezone the hour is in, such as "Europe/Paris", empty uses the configured one
+	Timezone string `json:"timezone"`
+
+	// Volume the alarm fades in to (1-100), 0 uses the configured alarm volume
+	Volume int `json:"volume"`
+	// Duration of the fade-in, 0 uses the configured one and a negative value
+	// rings at full volume right away
+	FadeInSeconds int `json:"fadeInSeconds"`
 }
 
-// FetchActiveAlarms fetches alarms based on the current time and activation status
-func FetchActiveAlarms(baseURL, currentTime string) ([]Alarm, error) {
-	filter := url.QueryEscape(fmt.Sprintf("hour='%s' && activated=true", currentTime))
-	queryURL := fmt.Sprintf("%s/api/collections/alarms/records?filter=%s", baseURL, filter)
-
-	utils.LogMessage("INFO", fmt.Sprintf("Fetching active alarms for hour: %s", currentTime), nil)
-
-	resp, err := http.Get(queryURL)
-	if err != nil {
-		utils.LogMessage("ERROR", "Failed to fetch alarms", map[string]interface{}{"error": err.Error()})
-		return nil, fmt.Errorf("failed to fetch alarms: %w", err)
-	}
-	defer resp.Body.Close()
-
-	body, _ := ioutil.ReadAll(resp.Body)
-	utils.LogMessage("DEBUG", "Response body received", nil)
-
-	if resp.StatusCode != http.StatusOK {
-		utils.LogMessage("ERROR", "Unexpected response while fetching alarms", map[string]interface{}{"status": resp.StatusCode, "body": string(body)})
-		return nil, fmt.Errorf("unexpected response: %s", string(body))
-	}
-
-	var response struct {
-		Items []Alarm `json:"items"`
-	}
-	if err := json.Unmarshal(body, &response); err != nil {
-		utils.LogMessage("ERROR", "Failed to decode alarms response", map[string]interface{}{"error": err.Error()})
-		return nil, fmt.Errorf("failed to decode alarms response: %w", err)
-	}
-
-	return response.Items, nil
+// RingsOnWeekday reports whether the alarm's weekday mask includes day
+func (a Alarm) RingsOnWeekday(day time.Weekday) bool {
+	return a.Weekdays == EveryDay || a.Weekdays&(1<<day) != 0
 }
 
-// CreateAlarm creates a new alarm in PocketBase
-func CreateAlarm(baseURL, playlistID, hour string, activated bool) (*Alarm, error) {
-	url := fmt.Sprintf("%s/api/collections/alarms/records", baseURL)
+// FiresOnce reports whether the alarm must be deactivated after ringing.
+// Alarms for a specific date cannot ring again once that date has passed.
+func (a Alarm) FiresOnce() bool {
+	return a.OneShot || a.Date != ""
+}
 
+// CreateAlarm creates a new alarm in PocketBase. The ID of the given alarm is ignored.
+func (c *Client) CreateAlarm(ctx context.Context, alarm Alarm) (*Alarm, error) {
 	payload := map[string]interface{}{
-		"playlistId": playlistID,
-		"hour":       hour,
-		"activated":  activated,
+		"playlistId":    alarm.PlaylistID,
+		"hour":          alarm.Hour,
+		"activated":     alarm.Activated,
+		"weekdays":      alarm.Weekdays,
+		"oneShot":       alarm.OneShot,
+		"date":          alarm.Date,
+		"timezone":      alarm.Timezone,
+		"volume":        alarm.Volume,
+		"fadeInSeconds": alarm.FadeInSeconds,
 	}
-	data, _ := json.Marshal(payload)
 
 	utils.LogMessage("INFO", "Creating a new alarm", map[string]interface{}{"payload": payload})
 
-	resp, err := http.Post(url, "application/json", bytes.NewBuffer(data))
-	if err != nil {
+	var created Alarm
+	if err := c.do(ctx, http.MethodPost, recordsPath("alarms", ""), nil, payload, &created); err != nil {
 		utils.LogMessage("ERROR", "Failed to create alarm", map[string]interface{}{"error": err.Error()})
 		return nil, fmt.Errorf("failed to create alarm: %w", err)
 	}
-	defer resp.Body.Close()
-
-	if resp.StatusCode != http.StatusOK && resp.StatusCode != http.StatusCreated {
-		body, _ := ioutil.ReadAll(resp.Body)
-		utils.LogMessage("ERROR", "Unexpected response while creating alarm", map[string]interface{}{"status": resp.StatusCode, "body": string(body)})
-		return nil, fmt.Errorf("unexpected response: %s", string(body))
-	}
 
-	var alarm Alarm
-	if err := json.NewDecoder(resp.Body).Decode(&alarm); err != nil {
-		utils.LogMessage("ERROR", "Failed to decode created alarm response", map[string]interface{}{"error": err.Error()})
-		return nil, fmt.Errorf("failed to decode alarm response: %w", err)
-	}
-
-	utils.LogMessage("INFO", "Alarm created successfully", map[string]interface{}{"alarm": alarm})
-	return &alarm, nil
+	utils.LogMessage("INFO", "Alarm created successfully", map[string]interface{}{"alarm": created})
+	return &created, nil
 }
 
 // DeleteAlarm deletes an alarm by ID
-func DeleteAlarm(baseURL, id string) error {
-	url := fmt.Sprintf("%s/api/collections/alarms/records/%s", baseURL, id)
-
+func (c *Client) DeleteAlarm(ctx context.Context, id string) error {
 	utils.LogMessage("INFO", "Deleting alarm", map[string]interface{}{"id": id})
 
-	req, err := http.NewRequest(http.MethodDelete, url, nil)
-	if err != nil {
-		utils.LogMessage("ERROR", "Failed to create delete request", map[string]interface{}{"error": err.Error()})
-		return fmt.Errorf("failed to create delete request: %w", err)
-	}
-
-	resp, err := http.DefaultClient.Do(req)
-	if err != nil {
+	if err := c.do(ctx, http.MethodDelete, recordsPath("alarms", id), nil, nil, nil); err != nil {
 		utils.LogMessage("ERROR", "Failed to delete alarm", map[string]interface{}{"error": err.Error()})
 		return fmt.Errorf("failed to delete alarm: %w", err)
 	}
-	defer resp.Body.Close()
-
-	if resp.StatusCode != http.StatusOK && resp.StatusCode != http.StatusNoContent {
-		body, _ := ioutil.ReadAll(resp.Body)
-		utils.LogMessage("ERROR", "Unexpected response while deleting alarm", map[string]interface{}{"status": resp.StatusCode, "body": string(body)})
-		return fmt.Errorf("unexpected response: %s", string(body))
-	}
 
 	utils.LogMessage("INFO", "Alarm deleted successfully", map[string]interface{}{"id": id})
 	return nil
 }
 
-// ListAlarms fetches all alarms
-func ListAlarms(baseURL string) ([]Alarm, error) {
-	url := fmt.Sprintf("%s/api/collections/alarms/records", baseURL)
-
+// ListAlarms fetches all alarms, going through every page of results
+func (c *Client) ListAlarms(ctx context.Context) ([]Alarm, error) {
 	utils.LogMessage("INFO", "Fetching all alarms", nil)
 
-	resp, err := http.Get(url)
+	alarms, err := c.listAlarms(ctx, "")
 	if err != nil {
 		utils.LogMessage("ERROR", "Failed to fetch alarms", map[string]interface{}{"error": err.Error()})
 		return nil, fmt.Errorf("failed to fetch alarms: %w", err)
 	}
-	defer resp.Body.Close()
 
-	if resp.StatusCode != http.StatusOK {
-		body, _ := ioutil.ReadAll(resp.Body)
-		utils.LogMessage("ERROR", "Unexpected response while listing alarms", map[string]interface{}{"status": resp.StatusCode, "body": string(body)})
-		return nil, fmt.Errorf("unexpected response: %s", string(body))
-	}
+	utils.LogMessage("INFO", "Fetched all alarms successfully", map[string]interface{}{"count": len(alarms)})
+	return alarms, nil
+}
 
-	var response struct {
-		Items []Alarm `json:"items"`
-	}
-	if err := json.NewDecoder(resp.Body).Decode(&response); err != nil {
-		utils.LogMessage("ERROR", "Failed to decode alarms response", map[string]interface{}{"error": err.Error()})
-		return nil, fmt.Errorf("failed to decode alarms response: %w", err)
+// ListPlaylistAlarms fetches the alarms that play a playlist
+func (c *Client) ListPlaylistAlarms(ctx context.Context, playlistID string) ([]Alarm, error) {
+	filter, err := Filter("playlistId = {:playlistId}", Params{"playlistId": playlistID})
+	if err != nil {
+		return nil, fmt.Errorf("failed to build alarms filter: %w", err)
 	}
 
-	utils.LogMessage("INFO", "Fetched all alarms successfully", map[string]interface{}{"count": len(response.Items)})
-	return response.Items, nil
+	alarms, err := c.listAlarms(ctx, filter)
+	if err != nil {
+		return nil, fmt.Errorf("failed to fetch playlist alarms: %w", err)
+	}
+	return alarms, nil
 }
 
-// SetAlarmStatus updates the status of an alarm
-func SetAlarmStatus(baseURL, id string, activated bool) error {
-	url := fmt.Sprintf("%s/api/collections/alarms/records/%s", baseURL, id)
+// listAlarms fetches all the alarms matching filter
+func (c *Client) listAlarms(ctx context.Context, filter string) ([]Alarm, error) {
+	alarms := []Alarm{}
+	err := c.listRecords(ctx, "alarms", filter, func(items json.RawMessage) error {
+		var page []Alarm
+		if err := json.Unmarshal(items, &page); err != nil {
+			return err
+		}
+		alarms = append(alarms, page...)
+		return nil
+	})
+	return alarms, err
+}
 
-	payload := map[string]interface{}{
-		"activated": activated,
-	}
-	data, _ := json.Marshal(payload)
+// updateAlarm patches the given fields of an alarm
+func (c *Client) updateAlarm(ctx context.Context, id string, fields map[string]interface{}) error {
+	return c.do(ctx, http.MethodPatch, recordsPath("alarms", id), nil, fields, nil)
+}
 
+// SetAlarmStatus updates the status of an alarm
+func (c *Client) SetAlarmStatus(ctx context.Context, id string, activated bool) error {
 	utils.LogMessage("INFO", "Updating alarm status", map[string]interface{}{"id": id, "activated": activated})
 
-	req, err := http.NewRequest(http.MethodPatch, url, bytes.NewBuffer(data))
-	if err != nil {
-		utils.LogMessage("ERROR", "Failed to create patch request", map[string]interface{}{"error": err.Error()})
-		return fmt.Errorf("failed to create patch request: %w", err)
-	}
-	req.Header.Set("Content-Type", "application/json")
-
-	resp, err := http.DefaultClient.Do(req)
-	if err != nil {
+	if err := c.updateAlarm(ctx, id, map[string]interface{}{"activated": activated}); err != nil {
 		utils.LogMessage("ERROR", "Failed to update alarm status", map[string]interface{}{"error": err.Error()})
 		return fmt.Errorf("failed to update alarm status: %w", err)
 	}
-	defer resp.Body.Close()
-
-	if resp.StatusCode != http.StatusOK {
-		body, _ := ioutil.ReadAll(resp.Body)
-		utils.LogMessage("ERROR", "Unexpected response while updating alarm status", map[string]interface{}{"status": resp.StatusCode, "body": string(body)})
-		return fmt.Errorf("unexpected response: %s", string(body))
-	}
 
 	utils.LogMessage("INFO", "Alarm status updated successfully", map[string]interface{}{"id": id, "activated": activated})
 	return nil
 }
 
 // ChangeAlarmPlaylist updates the playlist ID of an alarm
-func ChangeAlarmPlaylist(baseURL, id, playlistID string) error {
-	url := fmt.Sprintf("%s/api/collections/alarms/records/%s", baseURL, id)
-
-	payload := map[string]interface{}{
-		"playlistId": playlistID,
-	}
-	data, _ := json.Marshal(payload)
-
+func (c *Client) ChangeAlarmPlaylist(ctx context.Context, id, playlistID string) error {
 	utils.LogMessage("INFO", "Changing alarm playlist", map[string]interface{}{"id": id, "playlistId": playlistID})
 
-	req, err := http.NewRequest(http.MethodPatch, url, bytes.NewBuffer(data))
-	if err != nil {
-		utils.LogMessage("ERROR", "Failed to create patch request", map[string]interface{}{"error": err.Error()})
-		return fmt.Errorf("failed to create patch request: %w", err)
-	}
-	req.Header.Set("Content-Type", "application/json")
-
-	resp, err := http.DefaultClient.Do(req)
-	if err != nil {
+	if err := c.updateAlarm(ctx, id, map[string]interface{}{"playlistId": playlistID}); err != nil {
 		utils.LogMessage("ERROR", "Failed to change alarm playlist", map[string]interface{}{"error": err.Error()})
 		return fmt.Errorf("failed to change alarm playlist: %w", err)
 	}
-	defer resp.Body.Close()
-
-	if resp.StatusCode != http.StatusOK {
-		body, _ := ioutil.ReadAll(resp.Body)
-		utils.LogMessage("ERROR", "Unexpected response while changing alarm playlist", map[string]interface{}{"status": resp.StatusCode, "body": string(body)})
-		return fmt.Errorf("unexpected response: %s", string(body))
-	}
 
 	utils.LogMessage("INFO", "Alarm playlist changed successfully", map[string]interface{}{"id": id, "playlistId": playlistID})
 	return nil
 }
 
 // ChangeAlarmHour updates the hour of an alarm
-func ChangeAlarmHour(baseURL, id, hour string) error {
-	url := fmt.Sprintf("%s/api/collections/alarms/records/%s", baseURL, id)
+func (c *Client) ChangeAlarmHour(ctx context.Context, id, hour string) error {
+	utils.LogMessage("INFO", "Changing alarm hour", map[string]interface{}{"id": id, "hour": hour})
 
-	payload := map[string]interface{}{
-		"hour": hour,
+	if err := c.updateAlarm(ctx, id, map[string]interface{}{"hour": hour}); err != nil {
+		utils.LogMessage("ERROR", "Failed to change alarm hour", map[string]interface{}{"error": err.Error()})
+		return fmt.Errorf("failed to change alarm hour: %w", err)
 	}
-	data, _ := json.Marshal(payload)
 
-	utils.LogMessage("INFO", "Changing alarm hour", map[string]interface{}{"id": id, "hour": hour})
+	utils.LogMessage("INFO", "Alarm hour changed successfully", map[string]interface{}{"id": id, "hour": hour})
+	return nil
+}
 
-	req, err := http.NewRequest(http.MethodPatch, url, bytes.NewBuffer(data))
-	if err != nil {
-		utils.LogMessage("ERROR", "Failed to create patch request", map[string]interface{}{"error": err.Error()})
-		return fmt.Errorf("failed to create patch request: %w", err)
+// ChangeAlarmSchedule updates the weekdays, one-shot flag, date and timezone of an alarm
+func (c *Client) ChangeAlarmSchedule(ctx context.Context, id string, weekdays int, oneShot bool, date, timezone string) error {
+	fields := map[string]interface{}{
+		"weekdays": weekdays,
+		"oneShot":  oneShot,
+		"date":     date,
+		"timezone": timezone,
 	}
-	req.Header.Set("Content-Type", "application/json")
 
-	resp, err := http.DefaultClient.Do(req)
-	if err != nil {
-		utils.LogMessage("ERROR", "Failed to change alarm hour", map[string]interface{}{"error": err.Error()})
-		return fmt.Errorf("failed to change alarm hour: %w", err)
+	utils.LogMessage("INFO", "Changing alarm schedule", map[string]interface{}{"id": id, "schedule": fields})
+
+	if err := c.updateAlarm(ctx, id, fields); err != nil {
+		utils.LogMessage("ERROR", "Failed to change alarm schedule", map[string]interface{}{"error": err.Error()})
+		return fmt.Errorf("failed to change alarm schedule: %w", err)
 	}
-	defer resp.Body.Close()
 
-	if resp.StatusCode != http.StatusOK {
-		body, _ := ioutil.ReadAll(resp.Body)
-		utils.LogMessage("ERROR", "Unexpected response while changing alarm hour", map[string]interface{}{"status": resp.StatusCode, "body": string(body)})
-		return fmt.Errorf("unexpected response: %s", string(body))
+	utils.LogMessage("INFO", "Alarm schedule changed successfully", map[string]interface{}{"id": id})
+	return nil
+}
+
+// ChangeAlarmVolume updates the target volume and fade-in duration of an alarm
+func (c *Client) ChangeAlarmVolume(ctx context.Context, id string, volume, fadeInSeconds int) error {
+	fields := map[string]interface{}{
+		"volume":        volume,
+		"fadeInSeconds": fadeInSeconds,
 	}
 
-	utils.LogMessage("INFO", "Alarm hour changed successfully", map[string]interface{}{"id": id, "hour": hour})
+	utils.LogMessage("INFO", "Changing alarm volume", map[string]interface{}{"id": id, "volume": fields})
+
+	if err := c.updateAlarm(ctx, id, fields); err != nil {
+		utils.LogMessage("ERROR", "Failed to change alarm volume", map[string]interface{}{"error": err.Error()})
+		return fmt.Errorf("failed to change alarm volume: %w", err)
+	}
+
+	utils.LogMessage("INFO", "Alarm volume changed successfully", map[string]interface{}{"id": id})
 	return nil
-}
\ No newline at end of file
+}
@@ -1,43 +1,52 @@
 package pocketbase
 
 import (
-	"bytes"
+	"context"
 	"encoding/json"
 	"fmt"
-	"io/ioutil"
 	"net/http"
 	"net/url"
+	"time"
 )
 
+// DateTimeLayout is the layout of PocketBase datetime fields, always in UTC
+const DateTimeLayout = "2006-01-02 15:04:05.000Z"
+
 // Card represents a card object in PocketBase
 type Card struct {
 	ID         string `json:"id"`
 	UID        string `json:"uid"`
+	Label      string `json:"label"` // Name shown to users, such as what is drawn on the card
 	PlaylistID string `json:"playlistId"`
+	Action     string `json:"action"` // One of the constants.Action* values, empty plays the playlist
+
+	// When the card was last scanned in read mode, in DateTimeLayout
+	LastSeen string `json:"lastSeen,omitempty"`
+
+	// Where playback of the playlist was interrupted, used to resume it
+	QueuePosition int `json:"queuePosition"`
+	ProgressMs    int `json:"progressMs"`
+
+	// Owntone output IDs to play on, overriding the ones of the playlist
+	Outputs []string `json:"outputs,omitempty"`
 }
 
 // CheckCard checks if a card exists in the PocketBase database
-func CheckCard(baseURL, uid string) (*Card, error) {
-	filter := url.QueryEscape(fmt.Sprintf("uid='%s'", uid))
-	url := fmt.Sprintf("%s/api/collections/cards/records?filter=%s", baseURL, filter)
-
-	resp, err := http.Get(url)
+func (c *Client) CheckCard(ctx context.Context, uid string) (*Card, error) {
+	filter, err := Filter("uid = {:uid}", Params{"uid": uid})
 	if err != nil {
-		return nil, fmt.Errorf("failed to connect to PocketBase: %w", err)
-	}
-	defer resp.Body.Close()
-
-	if resp.StatusCode == http.StatusNotFound {
-		return nil, nil
-	} else if resp.StatusCode != http.StatusOK {
-		return nil, fmt.Errorf("unexpected response: %s", resp.Status)
+		return nil, fmt.Errorf("failed to build card filter: %w", err)
 	}
+	query := url.Values{"filter": {filter}}
 
 	var result struct {
 		Items []Card `json:"items"`
 	}
-	if err := json.NewDecoder(resp.Body).Decode(&result); err != nil {
-		return nil, fmt.Errorf("failed to parse response: %w", err)
+	if err := c.do(ctx, http.MethodGet, recordsPath("cards", ""), query, nil, &result); err != nil {
+		if isNotFound(err) {
+			return nil, nil
+		}
+		return nil, fmt.Errorf("failed to check card: %w", err)
 	}
 
 	if len(result.Items) == 0 {
@@ -47,54 +56,110 @@ func CheckCard(baseURL, uid string) (*Card, error) {
 	return &result.Items[0], nil
 }
 
-// AddCard adds a new card to the PocketBase database
-func AddCard(baseURL string, card Card) error {
-	url := fmt.Sprintf("%s/api/collections/cards/records", baseURL)
+// GetCard fetches a card by ID, returning nil when it does not exist
+func (c *Client) GetCard(ctx context.Context, id string) (*Card, error) {
+	var card Card
+	if err := c.do(ctx, http.MethodGet, recordsPath("cards", id), nil, nil, &card); err != nil {
+		if isNotFound(err) {
+			return nil, nil
+		}
+		return nil, fmt.Errorf("failed to fetch card: %w", err)
+	}
+	return &card, nil
+}
 
-	payload, err := json.Marshal(card)
+// ListCards fetches all cards
+func (c *Client) ListCards(ctx context.Context) ([]Card, error) {
+	cards, err := c.listCards(ctx, "")
 	if err != nil {
-		return fmt.Errorf("failed to marshal card: %w", err)
+		return nil, fmt.Errorf("failed to fetch cards: %w", err)
 	}
+	return cards, nil
+}
 
-	resp, err := http.Post(url, "application/json", bytes.NewBuffer(payload))
-	if err != nil {
+// AddCard adds a new card to the PocketBase database
+func (c *Client) AddCard(ctx context.Context, card Card) error {
+	if err := c.do(ctx, http.MethodPost, recordsPath("cards", ""), nil, card, nil); err != nil {
 		return fmt.Errorf("failed to add card: %w", err)
 	}
-	defer resp.Body.Close()
+	return nil
+}
 
-	if resp.StatusCode != http.StatusOK && resp.StatusCode != http.StatusCreated {
-		body, _ := ioutil.ReadAll(resp.Body)
-		return fmt.Errorf("unexpected response: %s", string(body))
+// UpdateCard changes the given fields of an existing card in PocketBase,
+// keyed by their JSON name. Fields left out keep their value.
+func (c *Client) UpdateCard(ctx context.Context, id string, fields map[string]interface{}) error {
+	if err := c.do(ctx, http.MethodPatch, recordsPath("cards", id), nil, fields, nil); err != nil {
+		return fmt.Errorf("failed to update card: %w", err)
 	}
+	return nil
+}
 
+// DeleteCard deletes a card by ID
+func (c *Client) DeleteCard(ctx context.Context, id string) error {
+	if err := c.do(ctx, http.MethodDelete, recordsPath("cards", id), nil, nil, nil); err != nil {
+		return fmt.Errorf("failed to delete card: %w", err)
+	}
 	return nil
 }
 
-// UpdateCard updates an existing card in PocketBase
-func UpdateCard(baseURL string, card Card) error {
-	url := fmt.Sprintf("%s/api/collections/cards/records/%s", baseURL, card.ID)
+// MarkCardSeen records that a card was scanned at the given time
+func (c *Client) MarkCardSeen(ctx context.Context, id string, at time.Time) error {
+	payload := map[string]interface{}{"lastSeen": at.UTC().Format(DateTimeLayout)}
+	if err := c.do(ctx, http.MethodPatch, recordsPath("cards", id), nil, payload, nil); err != nil {
+		return fmt.Errorf("failed to mark card as seen: %w", err)
+	}
+	return nil
+}
 
-	payload, err := json.Marshal(card)
-	if err != nil {
-		return fmt.Errorf("failed to marshal card: %w", err)
+// SaveCardPosition stores where playback of a card's playlist was interrupted
+func (c *Client) SaveCardPosition(ctx context.Context, id string, queuePosition, progressMs int) error {
+	payload := map[string]interface{}{
+		"queuePosition": queuePosition,
+		"progressMs":    progressMs,
+	}
+	if err := c.do(ctx, http.MethodPatch, recordsPath("cards", id), nil, payload, nil); err != nil {
+		return fmt.Errorf("failed to save card position: %w", err)
 	}
+	return nil
+}
 
-	req, err := http.NewRequest(http.MethodPatch, url, bytes.NewBuffer(payload))
-	if err != nil {
-		return fmt.Errorf("failed to create update request: %w", err)
+// UnassignCard removes the playlist of a card, along with its saved position
+func (c *Client) UnassignCard(ctx context.Context, id string) error {
+	payload := map[string]interface{}{
+		"playlistId":    "",
+		"queuePosition": 0,
+		"progressMs":    0,
+	}
+	if err := c.do(ctx, http.MethodPatch, recordsPath("cards", id), nil, payload, nil); err != nil {
+		return fmt.Errorf("failed to unassign card: %w", err)
 	}
-	req.Header.Set("Content-Type", "application/json")
+	return nil
+}
 
-	client := &http.Client{}
-	resp, err := client.Do(req)
+// ListPlaylistCards fetches the cards that play a playlist
+func (c *Client) ListPlaylistCards(ctx context.Context, playlistID string) ([]Card, error) {
+	filter, err := Filter("playlistId = {:playlistId}", Params{"playlistId": playlistID})
 	if err != nil {
-		return fmt.Errorf("failed to update card: %w", err)
+		return nil, fmt.Errorf("failed to build cards filter: %w", err)
 	}
-	defer resp.Body.Close()
 
-	if resp.StatusCode != http.StatusOK {
-		return fmt.Errorf("unexpected response: %s", resp.Status)
+	cards, err := c.listCards(ctx, filter)
+	if err != nil {
+		return nil, fmt.Errorf("failed to fetch playlist cards: %w", err)
 	}
+	return cards, nil
+}
 
-	return nil
-}
\ No newline at end of file
+// listCards fetches all the cards matching filter
+func (c *Client) listCards(ctx context.Context, filter string) ([]Card, error) {
+	cards := []Card{}
+	err := c.listRecords(ctx, "cards", filter, func(items json.RawMessage) error {
+		var page []Card
+		if err := json.Unmarshal(items, &page); err != nil {
+			return err
+		}
+		cards = append(cards, page...)
+		return nil
+	})
+	return cards, err
+}
@@ -0,0 +1,282 @@
+package pocketbase
+
+import (
+	"bytes"
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"cartophone-server/internal/utils"
+)
+
+// DefaultTimeout is used when the client is created without an http.Client
+const DefaultTimeout = 10 * time.Second
+
+// AdminsCollection selects the legacy admins API (PocketBase < 0.23) as the
+// auth collection. Newer versions authenticate admins through "_superusers".
+const AdminsCollection = "admins"
+
+// maxPerPage is the largest page size accepted by PocketBase
+const maxPerPage = 500
+
+// tokenRefreshMargin is how long before its expiry the auth token is refreshed
+const tokenRefreshMargin = 5 * time.Minute
+
+// Credentials identify the admin or auth collection record the client authenticates as
+type Credentials struct {
+	Collection string
+	Identity   string
+	Password   string
+}
+
+// APIError is returned when PocketBase answers with an unexpected status
+type APIError struct {
+	StatusCode int
+	Body       string
+}
+
+func (e *APIError) Error() string {
+	return fmt.Sprintf("unexpected response: %d %s", e.StatusCode, e.Body)
+}
+
+// isNotFound reports whether err is a PocketBase 404 response
+func isNotFound(err error) bool {
+	var apiErr *APIError
+	return errors.As(err, &apiErr) && apiErr.StatusCode == http.StatusNotFound
+}
+
+// Client talks to the PocketBase REST API, authenticating when credentials are set
+type Client struct {
+	baseURL     string
+	httpClient  *http.Client
+	credentials *Credentials
+
+	mu          sync.Mutex
+	token       string
+	tokenExpiry time.Time
+}
+
+// NewClient creates a client for the PocketBase server at baseURL. When
+// httpClient is nil, a client with DefaultTimeout is used. When credentials
+// is nil, requests are sent anonymously.
+func NewClient(baseURL string, httpClient *http.Client, credentials *Credentials) *Client {
+	if httpClient == nil {
+		httpClient = &http.Client{Timeout: DefaultTimeout}
+	}
+	return &Client{
+		baseURL:     strings.TrimRight(baseURL, "/"),
+		httpClient:  httpClient,
+		credentials: credentials,
+	}
+}
+
+// recordsPath returns the path of the records of a collection, or of a single record when id is set
+func recordsPath(collection, id string) string {
+	path := fmt.Sprintf("/api/collections/%s/records", collection)
+	if id != "" {
+		path += "/" + url.PathEscape(id)
+	}
+	return path
+}
+
+// listRecords fetches every page of the records of a collection matching
+// filter, or all of them when filter is empty. each receives the JSON array
+// of items of each page.
+func (c *Client) listRecords(ctx context.Context, collection, filter string, each func(items json.RawMessage) error) error {
+	for page := 1; ; page++ {
+		query := url.Values{
+			"page":    {strconv.Itoa(page)},
+			"perPage": {strconv.Itoa(maxPerPage)},
+		}
+		if filter != "" {
+			query.Set("filter", filter)
+		}
+
+		var response struct {
+			Items      json.RawMessage `json:"items"`
+			TotalPages int             `json:"totalPages"`
+		}
+		if err := c.do(ctx, http.MethodGet, recordsPath(collection, ""), query, nil, &response); err != nil {
+			return err
+		}
+		if err := each(response.Items); err != nil {
+			return fmt.Errorf("failed to decode %s: %w", collection, err)
+		}
+
+		if page >= response.TotalPages {
+			return nil
+		}
+	}
+}
+
+// do sends an authenticated request to the PocketBase API. body, when not
+// nil, is sent as JSON and out, when not nil, receives the decoded JSON
+// response. An expired token is renewed once when PocketBase rejects it.
+func (c *Client) do(ctx context.Context, method, path string, query url.Values, body, out interface{}) error {
+	token, err := c.authToken(ctx)
+	if err != nil {
+		return err
+	}
+
+	err = c.send(ctx, method, path, query, token, body, out)
+
+	var apiErr *APIError
+	if c.credentials != nil && errors.As(err, &apiErr) && apiErr.StatusCode == http.StatusUnauthorized {
+		utils.LogMessage("INFO", "PocketBase rejected the auth token, authenticating again", nil)
+		c.clearToken()
+		if token, err = c.authToken(ctx); err != nil {
+			return err
+		}
+		err = c.send(ctx, method, path, query, token, body, out)
+	}
+
+	return err
+}
+
+// send performs a single request to the PocketBase API
+func (c *Client) send(ctx context.Context, method, path string, query url.Values, token string, body, out interface{}) error {
+	endpoint := c.baseURL + path
+	if len(query) > 0 {
+		endpoint += "?" + query.Encode()
+	}
+
+	var reqBody io.Reader
+	if body != nil {
+		data, err := json.Marshal(body)
+		if err != nil {
+			return fmt.Errorf("failed to encode request: %w", err)
+		}
+		reqBody = bytes.NewReader(data)
+	}
+
+	req, err := http.NewRequestWithContext(ctx, method, endpoint, reqBody)
+	if err != nil {
+		return fmt.Errorf("failed to create request: %w", err)
+	}
+	if body != nil {
+		req.Header.Set("Content-Type", "application/json")
+	}
+	if token != "" {
+		req.Header.Set("Authorization", token)
+	}
+
+	resp, err := c.httpClient.Do(req)
+	if err != nil {
+		return fmt.Errorf("failed to connect to PocketBase: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK && resp.StatusCode != http.StatusCreated && resp.StatusCode != http.StatusNoContent {
+		respBody, _ := io.ReadAll(resp.Body)
+		return &APIError{StatusCode: resp.StatusCode, Body: strings.TrimSpace(string(respBody))}
+	}
+
+	if out != nil && resp.StatusCode != http.StatusNoContent {
+		if err := json.NewDecoder(resp.Body).Decode(out); err != nil {
+			return fmt.Errorf("failed to decode response: %w", err)
+		}
+	}
+
+	return nil
+}
+
+// authToken returns a valid auth token, authenticating or refreshing the
+// current token as needed. It returns "" when the client has no credentials.
+func (c *Client) authToken(ctx context.Context) (string, error) {
+	if c.credentials == nil {
+		return "", nil
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if c.token != "" && time.Until(c.tokenExpiry) > tokenRefreshMargin {
+		return c.token, nil
+	}
+
+	if c.token != "" && time.Now().Before(c.tokenExpiry) {
+		err := c.authenticate(ctx, "auth-refresh", nil, c.token)
+		if err == nil {
+			return c.token, nil
+		}
+		utils.LogMessage("ERROR", "Failed to refresh PocketBase auth token", err.Error())
+	}
+
+	credentials := map[string]string{
+		"identity": c.credentials.Identity,
+		"password": c.credentials.Password,
+	}
+	if err := c.authenticate(ctx, "auth-with-password", credentials, ""); err != nil {
+		return "", err
+	}
+	return c.token, nil
+}
+
+// authenticate calls an auth endpoint of the configured collection and
+// stores the returned token. The caller must hold c.mu.
+func (c *Client) authenticate(ctx context.Context, action string, body interface{}, token string) error {
+	path := fmt.Sprintf("/api/collections/%s/%s", url.PathEscape(c.credentials.Collection), action)
+	if c.credentials.Collection == AdminsCollection {
+		path = "/api/admins/" + action
+	}
+
+	var response struct {
+		Token string `json:"token"`
+	}
+	if err := c.send(ctx, http.MethodPost, path, nil, token, body, &response); err != nil {
+		return fmt.Errorf("failed to authenticate with PocketBase: %w", err)
+	}
+	if response.Token == "" {
+		return fmt.Errorf("failed to authenticate with PocketBase: no token in response")
+	}
+
+	c.token = response.Token
+	c.tokenExpiry = tokenExpiry(response.Token)
+	utils.LogMessage("INFO", "Authenticated with PocketBase", map[string]interface{}{
+		"collection": c.credentials.Collection,
+		"expiresAt":  c.tokenExpiry,
+	})
+	return nil
+}
+
+// clearToken forgets the current auth token
+func (c *Client) clearToken() {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.token = ""
+}
+
+// tokenExpiry reads the expiry time of a JWT. Tokens that cannot be parsed
+// are considered to expire right after the refresh margin, so that they get
+// renewed on the next request after it.
+func tokenExpiry(token string) time.Time {
+	fallback := time.Now().Add(tokenRefreshMargin + time.Minute)
+
+	parts := strings.Split(token, ".")
+	if len(parts) != 3 {
+		return fallback
+	}
+
+	payload, err := base64.RawURLEncoding.DecodeString(parts[1])
+	if err != nil {
+		return fallback
+	}
+
+	var claims struct {
+		Exp int64 `json:"exp"`
+	}
+	if err := json.Unmarshal(payload, &claims); err != nil || claims.Exp == 0 {
+		return fallback
+	}
+
+	return time.Unix(claims.Exp, 0)
+}
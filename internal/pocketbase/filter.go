@@ -0,0 +1,90 @@
+package pocketbase
+
+import (
+	"encoding/json"
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Params holds the values bound to the placeholders of a filter
+type Params map[string]interface{}
+
+// placeholderPattern matches the {:name} placeholders of a filter expression
+var placeholderPattern = regexp.MustCompile(`\{:(\w+)\}`)
+
+// Filter builds a PocketBase filter expression by replacing each {:name}
+// placeholder with the matching value from params, like pb.filter() in the
+// PocketBase JS SDK:
+//
+//	Filter("uid = {:uid} && activated = {:activated}", Params{"uid": uid, "activated": true})
+//
+// Strings are single quoted with their quotes escaped, so a value can never
+// change the structure of the expression. Only values should come from
+// user input, never the expression itself.
+func Filter(expr string, params Params) (string, error) {
+	var firstErr error
+
+	result := placeholderPattern.ReplaceAllStringFunc(expr, func(placeholder string) string {
+		name := placeholderPattern.FindStringSubmatch(placeholder)[1]
+
+		value, ok := params[name]
+		if !ok {
+			if firstErr == nil {
+				firstErr = fmt.Errorf("missing filter parameter %q", name)
+			}
+			return placeholder
+		}
+
+		literal, err := filterLiteral(value)
+		if err != nil && firstErr == nil {
+			firstErr = fmt.Errorf("invalid filter parameter %q: %w", name, err)
+		}
+		return literal
+	})
+
+	if firstErr != nil {
+		return "", firstErr
+	}
+	return result, nil
+}
+
+// filterLiteral formats a value as a PocketBase filter literal
+func filterLiteral(value interface{}) (string, error) {
+	switch v := value.(type) {
+	case nil:
+		return "null", nil
+	case bool:
+		return strconv.FormatBool(v), nil
+	case int:
+		return strconv.Itoa(v), nil
+	case int64:
+		return strconv.FormatInt(v, 10), nil
+	case float64:
+		return strconv.FormatFloat(v, 'f', -1, 64), nil
+	case string:
+		return quoteFilterString(v)
+	case time.Time:
+		return quoteFilterString(v.UTC().Format(DateTimeLayout))
+	default:
+		data, err := json.Marshal(v)
+		if err != nil {
+			return "", err
+		}
+		return quoteFilterString(string(data))
+	}
+}
+
+// quoteFilterString single quotes a string for a PocketBase filter.
+//
+// The filter parser ends a string at the first quote that is not preceded by
+// a backslash and only unescapes \' sequences, so quotes are escaped and a
+// trailing backslash, which would escape the closing quote, is rejected.
+func quoteFilterString(s string) (string, error) {
+	if strings.HasSuffix(s, `\`) {
+		return "", fmt.Errorf("value cannot end with a backslash")
+	}
+	return "'" + strings.ReplaceAll(s, "'", `\'`) + "'", nil
+}
@@ -0,0 +1,136 @@
+package pocketbase
+
+import (
+	"testing"
+	"time"
+)
+
+func TestFilter(t *testing.T) {
+	tests := []struct {
+		name   string
+		expr   string
+		params Params
+		want   string
+	}{
+		{
+			name:   "plain string",
+			expr:   "uid = {:uid}",
+			params: Params{"uid": "04a1b2c3"},
+			want:   "uid = '04a1b2c3'",
+		},
+		{
+			name:   "quotes",
+			expr:   "name = {:name}",
+			params: Params{"name": "it's ' || id != ''"},
+			want:   `name = 'it\'s \' || id != \'\''`,
+		},
+		{
+			name:   "backslashes",
+			expr:   "name = {:name}",
+			params: Params{"name": `a\b\\c`},
+			want:   `name = 'a\b\\c'`,
+		},
+		{
+			name:   "backslash before a quote",
+			expr:   "name = {:name}",
+			params: Params{"name": `a\'b`},
+			want:   `name = 'a\\'b'`,
+		},
+		{
+			name:   "placeholder inside a value",
+			expr:   "name = {:name} && uid = {:uid}",
+			params: Params{"name": "{:uid}", "uid": "x"},
+			want:   "name = '{:uid}' && uid = 'x'",
+		},
+		{
+			name:   "repeated placeholder",
+			expr:   "a = {:v} || b = {:v}",
+			params: Params{"v": "x"},
+			want:   "a = 'x' || b = 'x'",
+		},
+		{
+			name:   "unused parameter",
+			expr:   "uid = {:uid}",
+			params: Params{"uid": "x", "other": "y"},
+			want:   "uid = 'x'",
+		},
+		{
+			name: "non-string literals",
+			expr: "a = {:bool} && b = {:int} && c = {:int64} && d = {:float} && e = {:nil}",
+			params: Params{
+				"bool":  true,
+				"int":   -3,
+				"int64": int64(1) << 40,
+				"float": 1.5,
+				"nil":   nil,
+			},
+			want: "a = true && b = -3 && c = 1099511627776 && d = 1.5 && e = null",
+		},
+		{
+			name:   "time",
+			expr:   "lastSeen > {:at}",
+			params: Params{"at": time.Date(2024, 3, 31, 2, 30, 0, 5e6, time.FixedZone("CEST", 2*60*60))},
+			want:   "lastSeen > '2024-03-31 00:30:00.005Z'",
+		},
+		{
+			name:   "other values are JSON",
+			expr:   "outputs ?= {:outputs}",
+			params: Params{"outputs": []string{"it's"}},
+			want:   `outputs ?= '["it\'s"]'`,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := Filter(tt.expr, tt.params)
+			if err != nil {
+				t.Fatalf("Filter(%q) returned error: %v", tt.expr, err)
+			}
+			if got != tt.want {
+				t.Errorf("Filter(%q) = %q, want %q", tt.expr, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestFilterErrors(t *testing.T) {
+	tests := []struct {
+		name   string
+		expr   string
+		params Params
+	}{
+		{
+			name:   "missing parameter",
+			expr:   "uid = {:uid}",
+			params: Params{"id": "x"},
+		},
+		{
+			name:   "nil params",
+			expr:   "uid = {:uid}",
+			params: nil,
+		},
+		{
+			name:   "trailing backslash",
+			expr:   "name = {:name}",
+			params: Params{"name": `a\`},
+		},
+		{
+			name:   "lone backslash",
+			expr:   "name = {:name}",
+			params: Params{"name": `\`},
+		},
+		{
+			name:   "unsupported value",
+			expr:   "a = {:a}",
+			params: Params{"a": make(chan int)},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got, err := Filter(tt.expr, tt.params); err == nil {
+				t.Errorf("Filter(%q) = %q, want an error", tt.expr, got)
+			}
+		})
+	}
+}
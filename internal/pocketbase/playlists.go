@@ -1,9 +1,9 @@
 package pocketbase
 
 import (
+	"context"
 	"encoding/json"
 	"fmt"
-	"io/ioutil"
 	"net/http"
 )
 
@@ -11,28 +11,109 @@ import (
 type Playlist struct {
 	ID   string `json:"id"`
 	Name string `json:"name"`
-	URI  string `json:"uri"`
+
+	// Owntone URIs queued in order, such as albums, tracks or streams. URI is
+	// the first of them, and the only one for playlists saved before URIs.
+	URI  string   `json:"uri"`
+	URIs []string `json:"uris,omitempty"`
+
+	// Owntone output IDs to play on, empty keeps the selected outputs
+	Outputs []string `json:"outputs,omitempty"`
+
+	Shuffle       string `json:"shuffle,omitempty"`       // owntone.ShuffleOn or ShuffleOff, empty keeps the current mode
+	Repeat        string `json:"repeat,omitempty"`        // Owntone repeat mode, empty keeps the current one
+	StartPosition int    `json:"startPosition,omitempty"` // Queue position of the first track played
+	StartOffsetMs int    `json:"startOffsetMs,omitempty"` // Offset in the first track played
+}
+
+// QueueURIs returns the URIs to queue for the playlist
+func (p Playlist) QueueURIs() []string {
+	if len(p.URIs) > 0 {
+		return p.URIs
+	}
+	if p.URI != "" {
+		return []string{p.URI}
+	}
+	return nil
+}
+
+// Fields returns the editable fields of a playlist keyed by their JSON name,
+// with empty lists set so that an update can clear them
+func (p Playlist) Fields() map[string]interface{} {
+	uris := p.QueueURIs()
+	if uris == nil {
+		uris = []string{}
+	}
+	uri := ""
+	if len(uris) > 0 {
+		uri = uris[0]
+	}
+	outputs := p.Outputs
+	if outputs == nil {
+		outputs = []string{}
+	}
+	return map[string]interface{}{
+		"name":          p.Name,
+		"uri":           uri,
+		"uris":          uris,
+		"outputs":       outputs,
+		"shuffle":       p.Shuffle,
+		"repeat":        p.Repeat,
+		"startPosition": p.StartPosition,
+		"startOffsetMs": p.StartOffsetMs,
+	}
 }
 
 // GetPlaylist fetches a playlist by ID from the PocketBase database
-func GetPlaylist(baseURL, playlistID string) (*Playlist, error) {
-	url := fmt.Sprintf("%s/api/collections/playlists/records/%s", baseURL, playlistID)
+func (c *Client) GetPlaylist(ctx context.Context, playlistID string) (*Playlist, error) {
+	var playlist Playlist
+	if err := c.do(ctx, http.MethodGet, recordsPath("playlists", playlistID), nil, nil, &playlist); err != nil {
+		return nil, fmt.Errorf("failed to fetch playlist: %w", err)
+	}
+	return &playlist, nil
+}
 
-	resp, err := http.Get(url)
+// ListPlaylists fetches all playlists
+func (c *Client) ListPlaylists(ctx context.Context) ([]Playlist, error) {
+	playlists := []Playlist{}
+	err := c.listRecords(ctx, "playlists", "", func(items json.RawMessage) error {
+		var page []Playlist
+		if err := json.Unmarshal(items, &page); err != nil {
+			return err
+		}
+		playlists = append(playlists, page...)
+		return nil
+	})
 	if err != nil {
-		return nil, fmt.Errorf("failed to fetch playlist: %w", err)
+		return nil, fmt.Errorf("failed to fetch playlists: %w", err)
 	}
-	defer resp.Body.Close()
+	return playlists, nil
+}
 
-	if resp.StatusCode != http.StatusOK {
-		body, _ := ioutil.ReadAll(resp.Body)
-		return nil, fmt.Errorf("unexpected response: %s", string(body))
+// CreatePlaylist creates a new playlist. The ID of the given playlist is ignored.
+func (c *Client) CreatePlaylist(ctx context.Context, playlist Playlist) (*Playlist, error) {
+	var created Playlist
+	if err := c.do(ctx, http.MethodPost, recordsPath("playlists", ""), nil, playlist.Fields(), &created); err != nil {
+		return nil, fmt.Errorf("failed to create playlist: %w", err)
 	}
+	return &created, nil
+}
 
-	var playlist Playlist
-	if err := json.NewDecoder(resp.Body).Decode(&playlist); err != nil {
-		return nil, fmt.Errorf("failed to decode playlist response: %w", err)
+// UpdatePlaylist changes the given fields of a playlist, keyed by their JSON
+// name, and returns the updated playlist. Fields left out keep their value.
+func (c *Client) UpdatePlaylist(ctx context.Context, id string, fields map[string]interface{}) (*Playlist, error) {
+	var updated Playlist
+	if err := c.do(ctx, http.MethodPatch, recordsPath("playlists", id), nil, fields, &updated); err != nil {
+		return nil, fmt.Errorf("failed to update playlist: %w", err)
 	}
+	return &updated, nil
+}
 
-	return &playlist, nil
-}
\ No newline at end of file
+// DeletePlaylist deletes a playlist by ID. Cards and alarms referencing it
+// are left untouched.
+func (c *Client) DeletePlaylist(ctx context.Context, id string) error {
+	if err := c.do(ctx, http.MethodDelete, recordsPath("playlists", id), nil, nil, nil); err != nil {
+		return fmt.Errorf("failed to delete playlist: %w", err)
+	}
+	return nil
+}
@@ -0,0 +1,137 @@
+package pocketbase
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+)
+
+// Realtime record actions
+const (
+	ActionCreate = "create"
+	ActionUpdate = "update"
+	ActionDelete = "delete"
+)
+
+// maxEventSize is the largest realtime event accepted, in bytes
+const maxEventSize = 1 << 20
+
+// RealtimeEvent is a record change received from the realtime API
+type RealtimeEvent struct {
+	Topic  string          // Subscription the event was sent for, such as a collection name
+	Action string          // One of ActionCreate, ActionUpdate or ActionDelete
+	Record json.RawMessage // The record, as it is after the change
+}
+
+// Subscribe listens to changes of the given topics, such as collection names,
+// through the PocketBase realtime API. ready is called once the
+// subscriptions are registered, events sent after it are passed to handle.
+// Subscribe blocks until ctx is cancelled or the connection is lost, and
+// always returns an error.
+func (c *Client) Subscribe(ctx context.Context, topics []string, ready func(), handle func(RealtimeEvent)) error {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, c.baseURL+"/api/realtime", nil)
+	if err != nil {
+		return fmt.Errorf("failed to create realtime request: %w", err)
+	}
+	req.Header.Set("Accept", "text/event-stream")
+
+	// The stream stays open, so the request timeout of the client cannot apply
+	streamClient := &http.Client{Transport: c.httpClient.Transport}
+	resp, err := streamClient.Do(req)
+	if err != nil {
+		return fmt.Errorf("failed to connect to PocketBase realtime API: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		body, _ := io.ReadAll(resp.Body)
+		return &APIError{StatusCode: resp.StatusCode, Body: strings.TrimSpace(string(body))}
+	}
+
+	subscribed := false
+	err = readEvents(resp.Body, func(name string, data []byte) error {
+		if name == "PB_CONNECT" {
+			var connect struct {
+				ClientID string `json:"clientId"`
+			}
+			if err := json.Unmarshal(data, &connect); err != nil {
+				return fmt.Errorf("failed to decode realtime connect event: %w", err)
+			}
+
+			payload := map[string]interface{}{
+				"clientId":      connect.ClientID,
+				"subscriptions": topics,
+			}
+			if err := c.do(ctx, http.MethodPost, "/api/realtime", nil, payload, nil); err != nil {
+				return fmt.Errorf("failed to subscribe to realtime topics: %w", err)
+			}
+
+			subscribed = true
+			ready()
+			return nil
+		}
+
+		if !subscribed {
+			return nil
+		}
+
+		var event struct {
+			Action string          `json:"action"`
+			Record json.RawMessage `json:"record"`
+		}
+		if err := json.Unmarshal(data, &event); err != nil {
+			return fmt.Errorf("failed to decode realtime event: %w", err)
+		}
+		handle(RealtimeEvent{Topic: name, Action: event.Action, Record: event.Record})
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+
+	if ctx.Err() != nil {
+		return ctx.Err()
+	}
+	return fmt.Errorf("realtime connection closed by PocketBase")
+}
+
+// readEvents reads a server-sent events stream, calling handle with the name
+// and data of each event, until the stream ends or handle fails
+func readEvents(r io.Reader, handle func(name string, data []byte) error) error {
+	scanner := bufio.NewScanner(r)
+	scanner.Buffer(make([]byte, 0, 64*1024), maxEventSize)
+
+	var name string
+	var data []string
+	for scanner.Scan() {
+		line := scanner.Text()
+
+		if line == "" {
+			if len(data) > 0 {
+				if err := handle(name, []byte(strings.Join(data, "\n"))); err != nil {
+					return err
+				}
+			}
+			name, data = "", nil
+			continue
+		}
+
+		field, value, _ := strings.Cut(line, ":")
+		value = strings.TrimPrefix(value, " ")
+		switch field {
+		case "event":
+			name = value
+		case "data":
+			data = append(data, value)
+		}
+	}
+
+	if err := scanner.Err(); err != nil {
+		return fmt.Errorf("failed to read realtime stream: %w", err)
+	}
+	return nil
+}
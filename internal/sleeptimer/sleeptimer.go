@@ -0,0 +1,176 @@
+package sleeptimer
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"time"
+
+	"cartophone-server/internal/owntone"
+	"cartophone-server/internal/utils"
+)
+
+// ErrNotRunning is returned when cancelling while no sleep timer is running
+var ErrNotRunning = errors.New("no sleep timer is running")
+
+// Settings configures the sleep timer
+type Settings struct {
+	Duration time.Duration // Used when a timer is started without a duration
+	FadeOut  time.Duration // How long the volume takes to go down when the timer expires
+}
+
+// Status describes the running sleep timer
+type Status struct {
+	StartedAt        time.Time `json:"startedAt"`
+	EndsAt           time.Time `json:"endsAt"`
+	RemainingSeconds int       `json:"remainingSeconds"`
+	FadingOut        bool      `json:"fadingOut"`
+}
+
+// running is the state of the running sleep timer
+type running struct {
+	startedAt time.Time
+	endsAt    time.Time
+	timer     *time.Timer
+	cancel    context.CancelFunc // Stops the fade-out once it started
+	fadingOut bool
+}
+
+// Timer pauses Owntone after a while, fading the volume out first
+type Timer struct {
+	ownTone  *owntone.Client
+	settings Settings
+
+	mu      sync.Mutex
+	running *running
+}
+
+// New creates a sleep timer controlling the given Owntone player
+func New(ownTone *owntone.Client, settings Settings) *Timer {
+	return &Timer{ownTone: ownTone, settings: settings}
+}
+
+// Start starts the sleep timer, replacing the running one. A duration of 0
+// uses the configured one.
+func (t *Timer) Start(duration time.Duration) Status {
+	if duration <= 0 {
+		duration = t.settings.Duration
+	}
+
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	t.stop()
+
+	now := time.Now()
+	r := &running{startedAt: now, endsAt: now.Add(duration)}
+	r.timer = time.AfterFunc(duration, func() {
+		t.expire(r)
+	})
+	t.running = r
+
+	status := r.status(now)
+	utils.LogMessage("ACTION", "Sleep timer started", status)
+	return status
+}
+
+// Cancel stops the running sleep timer, restoring the volume if it was fading out
+func (t *Timer) Cancel() error {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	if t.running == nil {
+		return ErrNotRunning
+	}
+
+	t.stop()
+	utils.LogMessage("ACTION", "Sleep timer cancelled", nil)
+	return nil
+}
+
+// Status returns the running sleep timer, or nil when none is running
+func (t *Timer) Status() *Status {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	if t.running == nil {
+		return nil
+	}
+	status := t.running.status(time.Now())
+	return &status
+}
+
+// stop stops the running timer and its fade-out. The caller must hold t.mu.
+func (t *Timer) stop() {
+	if t.running == nil {
+		return
+	}
+	t.running.timer.Stop()
+	if t.running.cancel != nil {
+		t.running.cancel()
+	}
+	t.running = nil
+}
+
+// expire fades the volume out, pauses playback and restores the volume for
+// the next time something plays
+func (t *Timer) expire(r *running) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	t.mu.Lock()
+	if t.running != r {
+		t.mu.Unlock()
+		return
+	}
+	r.cancel = cancel
+	r.fadingOut = true
+	t.mu.Unlock()
+
+	utils.LogMessage("ACTION", "Sleep timer expired, fading out", nil)
+
+	// Restore the volume once done, even when the fade-out was cancelled
+	volume := -1
+	if status, err := t.ownTone.GetPlayerStatus(ctx); err != nil {
+		utils.LogMessage("ERROR", "Failed to read volume before fading out", err.Error())
+	} else {
+		volume = status.Volume
+		if err := t.ownTone.FadeVolume(ctx, volume, 0, t.settings.FadeOut); err != nil && ctx.Err() == nil {
+			utils.LogMessage("ERROR", "Failed to fade out", err.Error())
+		}
+	}
+
+	restoreCtx := context.Background()
+	if ctx.Err() == nil {
+		if err := t.ownTone.Pause(restoreCtx); err != nil {
+			utils.LogMessage("ERROR", "Failed to pause at the end of the sleep timer", err.Error())
+		} else {
+			utils.LogMessage("ACTION", "Playback paused by the sleep timer", nil)
+		}
+	}
+	if volume >= 0 {
+		if err := t.ownTone.SetVolume(restoreCtx, volume); err != nil {
+			utils.LogMessage("ERROR", "Failed to restore volume after the sleep timer", err.Error())
+		}
+	}
+
+	t.mu.Lock()
+	if t.running == r {
+		t.running = nil
+	}
+	t.mu.Unlock()
+}
+
+// status describes the timer at the given time
+func (r *running) status(now time.Time) Status {
+	remaining := r.endsAt.Sub(now)
+	if remaining < 0 {
+		remaining = 0
+	}
+	return Status{
+		StartedAt:        r.startedAt,
+		EndsAt:           r.endsAt,
+		RemainingSeconds: int(remaining.Seconds()),
+		FadingOut:        r.fadingOut,
+	}
+}